@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/c-goetz/traitor-card-game/bot"
+	"github.com/c-goetz/traitor-card-game/game"
+	"github.com/c-goetz/traitor-card-game/lobby"
+)
+
+/*
+JSON API for bots and alternative clients, all paths are below /api/.
+Lobby ids are the url safe ids also used by the html pages.
+Requests acting as a player need the session either as cookie
+or as header "Authorization: Bearer <session>".
+
+	GET    /api/rulesets                                        names of all rulesets, 200 ["Classic"]
+	POST   /api/lobbies            {"name": "...", "variant": "Timebomb"}  create lobby, 201 apiSeat
+	POST   /api/lobbies/{id}/join  {"name": "..."}              join lobby or take a vacant seat, 201 apiSeat
+	POST   /api/lobbies/{id}/leave                              give up own seat, 204
+	GET    /api/lobbies/{id}                                    public state, 200 apiState
+	GET    /api/lobbies/{id}/spectate                           lobby.SpectatorView, delayed by the spectator delay, 200
+	DELETE /api/lobbies/{id}                                    close lobby, host only, 204
+	POST   /api/lobbies/{id}/name  {"name": "..."}              rename, 204
+	POST   /api/lobbies/{id}/start                              start or restart game, host only, 204
+	POST   /api/lobbies/{id}/kick  {"seat": 2}                  remove player between games, host only, 204
+	POST   /api/lobbies/{id}/host  {"seat": 2}                  make seat host, host only, 204
+	POST   /api/lobbies/{id}/lock  {"locked": true}             stop or allow joining, host only, 204
+	POST   /api/lobbies/{id}/options {"lockClaims": true, "ruleset": "Classic", "spectatorDelay": 30}  lobby.Options of the next game, host only, 204
+	POST   /api/lobbies/{id}/bots  {"name": "Bot", "strategy": "honest"}  seat a bot or fill a vacant seat, host only, 201 {"name": "Bot 2"}
+	POST   /api/lobbies/{id}/claim {"neutral": 1, "good": 2, "bad": 0, "special": 0}  claim hand, 204
+	POST   /api/lobbies/{id}/play  {"to": 2}                    reveal a card of seat "to", 204
+	GET    /api/lobbies/{id}/hand                               own hand, 200 {"neutral", "good", "bad", "special"}
+	GET    /api/lobbies/{id}/role                               own role, 200 {"role": "Good"}
+	GET    /api/lobbies/{id}/view                               own lobby.PlayerView, 200
+	GET    /api/lobbies/{id}/events                             all game.Event once the game ended, 200
+	GET    /api/lobbies/{id}/chat                               last lobby.ChatMessages, 200
+	POST   /api/lobbies/{id}/chat  {"text": "..."}              send chat message, 204
+	POST   /api/lobbies/{id}/mute  {"seat": 2, "muted": true}   forbid or allow seat to chat, host only, 204
+
+Errors are returned with a matching status code and body apiError.
+Moves breaking the rules of the game are "invalid_action", rule is the game.ErrorCode.
+Cards are counted by kind, the lobby.PlayerView.Variant names them. Only some variants deal special cards.
+Bot strategies are listed in bot.Strategies, empty plays honest when good and deceptive when bad.
+*/
+
+type apiError struct {
+	Error string `json:"error"`
+	// Rule is the game.ErrorCode of an invalid action
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+type apiSeat struct {
+	Lobby   string `json:"lobby"`
+	Seat    uint   `json:"seat"`
+	Session string `json:"session"`
+}
+
+type apiState struct {
+	Players  []string      `json:"players"`
+	Started  bool          `json:"started"`
+	State    string        `json:"state,omitempty"`
+	Revealed game.Cards    `json:"revealed"`
+	Claims   []*game.Cards `json:"claims"`
+	// Result is only set once the game ended
+	Result *game.GameResult `json:"result,omitempty"`
+}
+
+type apiName struct {
+	Name string `json:"name"`
+}
+
+type apiCreate struct {
+	Name    string `json:"name"`
+	Variant string `json:"variant"`
+}
+
+type apiSeatNumber struct {
+	Seat uint `json:"seat"`
+}
+
+type apiLock struct {
+	Locked bool `json:"locked"`
+}
+
+type apiBot struct {
+	Name     string `json:"name"`
+	Strategy string `json:"strategy"`
+}
+
+type apiChat struct {
+	Text string `json:"text"`
+}
+
+type apiMute struct {
+	Seat  uint `json:"seat"`
+	Muted bool `json:"muted"`
+}
+
+type apiPlay struct {
+	To uint `json:"to"`
+}
+
+type apiRole struct {
+	Role string `json:"role"`
+}
+
+var errUnknownEndpoint = errors.New("unknown endpoint")
+
+func serveAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+	if parts[0] == "rulesets" && len(parts) == 1 && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, lobby.Rulesets())
+		return
+	}
+	if parts[0] != "lobbies" || len(parts) > 3 {
+		writeAPIError(w, errUnknownEndpoint)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodPost {
+			writeAPIError(w, errUnknownEndpoint)
+			return
+		}
+		var body apiCreate
+		if !readJSON(w, r, &body) {
+			return
+		}
+		lobbyId, token, err := lobby.CreateLobbyWith(body.Name, lobby.Options{Variant: body.Variant})
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		setSession(w, lobbyId, token)
+		writeJSON(w, http.StatusCreated, apiSeat{encodeLobbyId(lobbyId), 0, sessionValue(lobbyId, token)})
+		return
+	}
+	lobbyId, err := decodeLobbyId(parts[1])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_lobby_id", Message: err.Error()})
+		return
+	}
+	var action string
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+
+	// endpoints without session
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		state, err := lobby.GetPublicState(lobbyId)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		s := apiState{state.Players, state.Started, "", state.Revealed, state.Claims, state.Result}
+		if state.Started {
+			s.State = state.State.String()
+		}
+		writeJSON(w, http.StatusOK, s)
+		return
+	case action == "spectate" && r.Method == http.MethodGet:
+		view, err := lobby.GetSpectatorView(lobbyId)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, view)
+		return
+	case action == "join" && r.Method == http.MethodPost:
+		var body apiName
+		if !readJSON(w, r, &body) {
+			return
+		}
+		seat, token, err := lobby.Join(lobbyId, body.Name)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		setSession(w, lobbyId, token)
+		writeJSON(w, http.StatusCreated, apiSeat{parts[1], seat, sessionValue(lobbyId, token)})
+		return
+	}
+
+	session, err := getSession(r)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthenticated", Message: err.Error()})
+		return
+	}
+	if session.Lobby != lobbyId {
+		writeAPIError(w, lobby.ErrUnauthorized)
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		writeAPIResult(w, lobby.Disband(lobbyId, session.Seat, session.Token))
+	case action == "name" && r.Method == http.MethodPost:
+		var body apiName
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.SetName(lobbyId, session.Seat, session.Token, body.Name))
+	case action == "leave" && r.Method == http.MethodPost:
+		writeAPIResult(w, lobby.Leave(lobbyId, session.Seat, session.Token))
+	case action == "start" && r.Method == http.MethodPost:
+		writeAPIResult(w, lobby.Start(lobbyId, session.Seat, session.Token))
+	case action == "kick" && r.Method == http.MethodPost:
+		var body apiSeatNumber
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.Kick(lobbyId, session.Seat, session.Token, body.Seat))
+	case action == "host" && r.Method == http.MethodPost:
+		var body apiSeatNumber
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.TransferHost(lobbyId, session.Seat, session.Token, body.Seat))
+	case action == "lock" && r.Method == http.MethodPost:
+		var body apiLock
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.SetLocked(lobbyId, session.Seat, session.Token, body.Locked))
+	case action == "options" && r.Method == http.MethodPost:
+		var body lobby.Options
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.SetOptions(lobbyId, session.Seat, session.Token, body))
+	case action == "bots" && r.Method == http.MethodPost:
+		body := apiBot{Name: "Bot"}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		b, err := addBot(lobbyId, session, body)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, apiName{b.Name})
+	case action == "chat" && r.Method == http.MethodGet:
+		chat, err := lobby.GetChat(lobbyId, session.Seat, session.Token)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, chat)
+	case action == "chat" && r.Method == http.MethodPost:
+		var body apiChat
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.Chat(lobbyId, session.Seat, session.Token, body.Text))
+	case action == "mute" && r.Method == http.MethodPost:
+		var body apiMute
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.Mute(lobbyId, session.Seat, session.Token, body.Seat, body.Muted))
+	case action == "claim" && r.Method == http.MethodPost:
+		var body game.Cards
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.Claim(lobbyId, session.Seat, session.Token, body))
+	case action == "play" && r.Method == http.MethodPost:
+		var body apiPlay
+		if !readJSON(w, r, &body) {
+			return
+		}
+		writeAPIResult(w, lobby.Play(lobbyId, session.Seat, session.Token, body.To))
+	case action == "hand" && r.Method == http.MethodGet:
+		hand, err := lobby.GetHand(lobbyId, session.Seat, session.Token)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, hand)
+	case action == "role" && r.Method == http.MethodGet:
+		role, err := lobby.GetRole(lobbyId, session.Seat, session.Token)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, apiRole{role.String()})
+	case action == "view" && r.Method == http.MethodGet:
+		view, err := lobby.GetView(lobbyId, session.Seat, session.Token)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, view)
+	case action == "events" && r.Method == http.MethodGet:
+		events, err := lobby.GetEvents(lobbyId, session.Seat, session.Token)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	default:
+		writeAPIError(w, errUnknownEndpoint)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<12)).Decode(v)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_body", Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("api: encode response: %v", err)
+	}
+}
+
+func writeAPIResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addBot seats a bot.Bot in the lobby of session, session must belong to the host.
+func addBot(lobbyId uint, session Session, body apiBot) (*bot.Bot, error) {
+	view, err := lobby.GetView(lobbyId, session.Seat, session.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !view.IsHost() {
+		return nil, fmt.Errorf("%w: only the host adds bots", lobby.ErrUnauthorized)
+	}
+	strategy, ok := bot.NewStrategy(body.Strategy, nil)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown strategy %s", lobby.ErrInvalidAction, body.Strategy)
+	}
+	return bot.Join(lobbyId, body.Name, strategy)
+}
+
+// writeAPIError maps errors of the lobby package to status and error code.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status, code, rule := http.StatusInternalServerError, "internal", ""
+	switch {
+	case errors.Is(err, errUnknownEndpoint):
+		status, code = http.StatusNotFound, "unknown_endpoint"
+	case errors.Is(err, lobby.ErrLobbyNotFound):
+		status, code = http.StatusNotFound, "lobby_not_found"
+	case errors.Is(err, lobby.ErrUnauthorized):
+		status, code = http.StatusForbidden, "unauthorized"
+	case errors.Is(err, lobby.ErrLobbyFull):
+		status, code = http.StatusConflict, "lobby_full"
+	case errors.Is(err, lobby.ErrNameTaken):
+		status, code = http.StatusConflict, "name_taken"
+	case errors.Is(err, lobby.ErrLocked):
+		status, code = http.StatusConflict, "lobby_locked"
+	case errors.Is(err, lobby.ErrInvalidName):
+		status, code = http.StatusBadRequest, "invalid_name"
+	case errors.Is(err, lobby.ErrNotStarted):
+		status, code = http.StatusConflict, "not_started"
+	case errors.Is(err, lobby.ErrStarted):
+		status, code = http.StatusConflict, "already_started"
+	case errors.Is(err, lobby.ErrPaused):
+		status, code = http.StatusConflict, "paused"
+	case errors.Is(err, lobby.ErrMuted):
+		status, code = http.StatusForbidden, "muted"
+	case errors.Is(err, lobby.ErrRateLimited):
+		status, code = http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, lobby.ErrInvalidMessage):
+		status, code = http.StatusBadRequest, "invalid_message"
+	case errors.Is(err, lobby.ErrInvalidAction):
+		status, code = http.StatusConflict, "invalid_action"
+		var gameErr *game.Error
+		if errors.As(err, &gameErr) {
+			rule = gameErr.Code.String()
+		}
+	default:
+		log.Printf("api: %v", err)
+	}
+	writeJSON(w, status, apiError{code, rule, err.Error()})
+}
@@ -0,0 +1,124 @@
+// Package bot seats computer players in a Lobby.
+// Bots are clients like the html pages and the API: they join, register a channel
+// and only ever see the lobby.PlayerView of their own seat.
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/c-goetz/traitor-card-game/game"
+	"github.com/c-goetz/traitor-card-game/lobby"
+)
+
+// maxSuffix is how many numbered names Join tries if a name is taken.
+const maxSuffix = 32
+
+// Bot plays the seat owning token until it is removed from the Lobby or the Lobby closes.
+type Bot struct {
+	Lobby    uint
+	Name     string
+	token    string
+	strategy Strategy
+	done     chan struct{}
+}
+
+// Join seats a new Bot in lobby, it takes a vacant seat if there is one.
+// If name is taken a number is appended.
+func Join(lobbyId uint, name string, strategy Strategy) (*Bot, error) {
+	joinAs := name
+	for i := 2; ; i++ {
+		_, token, err := lobby.Join(lobbyId, joinAs)
+		if errors.Is(err, lobby.ErrNameTaken) && i <= maxSuffix {
+			joinAs = fmt.Sprintf("%s %d", name, i)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Attach(lobbyId, joinAs, token, strategy)
+	}
+}
+
+// Attach lets a Bot play the seat owning token, e.g. the one of the host.
+// The Lobby gives up the seat on restarts, see lobby.PlayAsBot.
+func Attach(lobbyId uint, name string, token string, strategy Strategy) (*Bot, error) {
+	err := lobby.PlayAsBot(lobbyId, token)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bot{lobbyId, name, token, strategy, make(chan struct{})}
+	channel, err := b.register()
+	if err != nil {
+		return nil, err
+	}
+	go b.run(channel)
+	return b, nil
+}
+
+// Done is closed once the Bot stopped playing.
+func (b *Bot) Done() <-chan struct{} {
+	return b.done
+}
+
+// register attaches a new channel to the seat of b, the Lobby sends a ViewMessage first.
+func (b *Bot) register() (chan lobby.Message, error) {
+	channel := make(chan lobby.Message)
+	err := lobby.Register(b.Lobby, b.token, &channel)
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// run acts on every Message until the Lobby closes the channel for good.
+// Channels detached for lagging behind are registered again.
+func (b *Bot) run(channel chan lobby.Message) {
+	defer close(b.done)
+	for {
+		for message := range channel {
+			if _, ok := message.(*lobby.ClosingMessage); ok {
+				// the lobby closes the channel next
+				return
+			}
+			err := b.act()
+			if err != nil {
+				log.Printf("bot %s in lobby %d: %v", b.Name, b.Lobby, err)
+			}
+		}
+		var err error
+		channel, err = b.register()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// act makes the move the seat of b is expected to make, if any.
+// Seats move when others leave between games, so the seat is looked up every time.
+func (b *Bot) act() error {
+	seat, err := lobby.Authenticate(b.Lobby, b.token)
+	if err != nil {
+		return err
+	}
+	view, err := lobby.GetView(b.Lobby, seat, b.token)
+	if err != nil {
+		return err
+	}
+	v := view.Game
+	if v == nil || v.Result != nil {
+		return nil
+	}
+	switch {
+	case v.State == game.StateClaiming && v.Claims[v.Seat] == nil && !v.IsSilenced():
+		err = lobby.Claim(b.Lobby, seat, b.token, b.strategy.Claim(*v))
+	case v.State == game.StatePlaying && v.KeyHolder == v.Seat:
+		err = lobby.Play(b.Lobby, seat, b.token, uint(b.strategy.Play(*v)))
+	}
+	if errors.Is(err, lobby.ErrPaused) {
+		// the next player taking the vacant seat wakes all bots
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+	"github.com/c-goetz/traitor-card-game/lobby"
+)
+
+func TestBotsPlayToEnd(t *testing.T) {
+	id, host, err := lobby.CreateLobby("host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lobby.Close(id)
+	hostBot, err := Attach(id, "host", host, Random{rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range Strategies {
+		strategy, _ := NewStrategy(name, rand.NewSource(int64(i)))
+		if _, err := Join(id, "Bot", strategy); err != nil {
+			t.Fatalf("expected bot %s to join, got: %v", name, err)
+		}
+	}
+	state, _ := lobby.GetPublicState(id)
+	if len(state.Players) != 4 || state.Players[2] != "Bot 2" {
+		t.Fatalf("expected numbered bot names, got: %v", state.Players)
+	}
+	err = lobby.Start(id, 0, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for state.Result == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected bots to finish the game, got: %+v", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+		state, _ = lobby.GetPublicState(id)
+	}
+	lobby.Close(id)
+	select {
+	case <-hostBot.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected bot to stop once the lobby closed")
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	v := game.View{
+		PublicView: game.PublicView{
+			Revealed: game.Cards{Neutral: 3, Good: 1},
+			Cards:    game.Cards{Neutral: 15, Good: 4, Bad: 1},
+			Claims:   []*game.Cards{nil, {Neutral: 4}, {Good: 3, Neutral: 1}, {Bad: 1, Neutral: 3}},
+			Targets:  []game.Player{1, 2, 3},
+		},
+		Hand: game.Cards{Neutral: 2, Good: 1, Bad: 1},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range Strategies {
+		s, _ := NewStrategy(name, rand.NewSource(1))
+		if claim := s.Claim(v); count(claim) != count(v.Hand) {
+			t.Fatalf("expected %s to claim %d cards, got: %+v", name, count(v.Hand), claim)
+		}
+	}
+	if claim := (Honest{rng}).Claim(v); claim != v.Hand {
+		t.Fatalf("expected honest claim, got: %+v", claim)
+	}
+	if to := (Honest{rng}).Play(v); to != 2 {
+		t.Fatalf("expected honest bot to go for claimed good cards, got: %d", to)
+	}
+	if to := (Deceptive{rng}).Play(v); to != 3 {
+		t.Fatalf("expected deceptive bot to go for claimed bad cards, got: %d", to)
+	}
+	// 3 good cards left, all of them claimed by player 2
+	if claim := (Deceptive{rng}).Claim(v); claim.Good != 0 || claim.Neutral != 4 {
+		t.Fatalf("expected deceptive bot to hide its cards as neutral, got: %+v", claim)
+	}
+	v.Claims[2] = &game.Cards{Neutral: 4}
+	if claim := (Deceptive{rng}).Claim(v); claim.Good != 1 || claim.Bad != 0 {
+		t.Fatalf("expected deceptive bot to claim its bad card as good, got: %+v", claim)
+	}
+}
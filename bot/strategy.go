@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"math/rand"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// Strategy decides the moves of a Bot from the game.View of its seat.
+// A Strategy is only used by one Bot, it doesn't need to be safe for concurrent use.
+type Strategy interface {
+	// Claim returns what the bot says it holds, as many cards as v.Hand
+	Claim(v game.View) game.Cards
+	// Play returns one of v.Targets to reveal a card of
+	Play(v game.View) game.Player
+}
+
+// NewStrategy returns the Strategy called name, it draws from src.
+// Empty is ByRole, honest when good and deceptive when bad.
+// If src is nil game.NewCryptoSource is used.
+func NewStrategy(name string, src rand.Source) (Strategy, bool) {
+	if src == nil {
+		src = game.NewCryptoSource()
+	}
+	rng := rand.New(src)
+	switch name {
+	case "":
+		return ByRole{Honest{rng}, Deceptive{rng}}, true
+	case "random":
+		return Random{rng}, true
+	case "honest":
+		return Honest{rng}, true
+	case "deceptive":
+		return Deceptive{rng}, true
+	}
+	return nil, false
+}
+
+// Strategies are the names NewStrategy knows.
+var Strategies = []string{"random", "honest", "deceptive"}
+
+// ByRole plays Good as good player and Bad as bad player.
+type ByRole struct {
+	Good, Bad Strategy
+}
+
+func (s ByRole) Claim(v game.View) game.Cards {
+	if v.Role == game.RoleBad {
+		return s.Bad.Claim(v)
+	}
+	return s.Good.Claim(v)
+}
+
+func (s ByRole) Play(v game.View) game.Player {
+	if v.Role == game.RoleBad {
+		return s.Bad.Play(v)
+	}
+	return s.Good.Play(v)
+}
+
+// Random claims random cards and reveals cards of random players.
+type Random struct {
+	Rng *rand.Rand
+}
+
+func (s Random) Claim(v game.View) game.Cards {
+	var claim game.Cards
+	for i := 0; i < count(v.Hand); i++ {
+		switch s.Rng.Intn(3) {
+		case 0:
+			claim.Neutral++
+		case 1:
+			claim.Good++
+		default:
+			claim.Bad++
+		}
+	}
+	return claim
+}
+
+func (s Random) Play(v game.View) game.Player {
+	return v.Targets[s.Rng.Intn(len(v.Targets))]
+}
+
+// Honest tells the truth and reveals cards of the players that claim good cards.
+// The more good cards are claimed than are left, the less it trusts claims.
+type Honest struct {
+	Rng *rand.Rand
+}
+
+func (s Honest) Claim(v game.View) game.Cards {
+	return v.Hand
+}
+
+func (s Honest) Play(v game.View) game.Player {
+	good, bad := trust(v)
+	return best(s.Rng, v, func(p game.Player, claim game.Cards) float64 {
+		return good*share(claim.Good, claim) - bad*share(claim.Bad, claim)
+	})
+}
+
+// Deceptive lies as long as it stays believable.
+// It hides its good cards as neutral ones and claims its bad cards as good,
+// unless more good cards would be claimed than are left to reveal.
+// It reveals cards of the players least likely to hold good cards.
+type Deceptive struct {
+	Rng *rand.Rand
+}
+
+func (s Deceptive) Claim(v game.View) game.Cards {
+	claimed := 0
+	for p, claim := range v.Claims {
+		if claim != nil && game.Player(p) != v.Seat {
+			claimed += int(claim.Good)
+		}
+	}
+	left := int(v.Cards.Good) - int(v.Revealed.Good) - claimed
+	claim := game.Cards{Neutral: v.Hand.Neutral + v.Hand.Good, Special: v.Hand.Special}
+	for i := uint8(0); i < v.Hand.Bad; i++ {
+		if left > 0 {
+			left--
+			claim.Good++
+		} else {
+			claim.Neutral++
+		}
+	}
+	return claim
+}
+
+func (s Deceptive) Play(v game.View) game.Player {
+	good, bad := trust(v)
+	return best(s.Rng, v, func(p game.Player, claim game.Cards) float64 {
+		return bad*share(claim.Bad, claim) - good*share(claim.Good, claim)
+	})
+}
+
+// trust tells how much claims of good and bad cards can be believed, from 0 to 1.
+// Claims naming more cards of a kind than are left to reveal contain lies.
+func trust(v game.View) (good, bad float64) {
+	var claimed game.Cards
+	for _, claim := range v.Claims {
+		if claim != nil {
+			claimed.Good += claim.Good
+			claimed.Bad += claim.Bad
+		}
+	}
+	ratio := func(left, claimed uint8) float64 {
+		if claimed <= left {
+			return 1
+		}
+		return float64(left) / float64(claimed)
+	}
+	return ratio(v.Cards.Good-v.Revealed.Good, claimed.Good), ratio(v.Cards.Bad-v.Revealed.Bad, claimed.Bad)
+}
+
+// best returns the target with the highest score, ties are broken at random.
+// Claims of players known to be bad or without claim count as unknown.
+func best(rng *rand.Rand, v game.View, score func(p game.Player, claim game.Cards) float64) game.Player {
+	var candidates []game.Player
+	var max float64
+	for _, p := range v.Targets {
+		var s float64
+		if claim := v.Claims[p]; claim != nil && !knownBad(v, p) {
+			s = score(p, *claim)
+		}
+		if len(candidates) == 0 || s > max {
+			candidates, max = []game.Player{p}, s
+		} else if s == max {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates[rng.Intn(len(candidates))]
+}
+
+func knownBad(v game.View, p game.Player) bool {
+	return int(p) < len(v.KnownRoles) && v.KnownRoles[p] != nil && *v.KnownRoles[p] == game.RoleBad
+}
+
+// share of cards in claim.
+func share(cards uint8, claim game.Cards) float64 {
+	n := count(claim)
+	if n == 0 {
+		return 0
+	}
+	return float64(cards) / float64(n)
+}
+
+func count(c game.Cards) int {
+	return int(c.Neutral) + int(c.Good) + int(c.Bad) + int(c.Special)
+}
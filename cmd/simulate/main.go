@@ -0,0 +1,223 @@
+// Command simulate plays many games between bots to check the balance of rulesets.
+// For every ruleset and player count it reports how often each side wins,
+// how long games last and why they end, as table or CSV.
+//
+//	go run ./cmd/simulate -games 10000 -rulesets rulesets -csv
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/c-goetz/traitor-card-game/bot"
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// stats sums up the games played by one ruleset with one player count.
+type stats struct {
+	rules   string
+	players int
+	games   int
+	wins    map[game.Role]int
+	reasons map[game.EndReason]int
+	rounds  int
+	cards   int
+}
+
+var header = []string{"ruleset", "players", "games", "good %", "bad %", "rounds", "cards", "good revealed %", "bad revealed %", "rounds exhausted %"}
+
+func (s *stats) add(result game.GameResult, revealed game.Cards) {
+	s.games++
+	s.wins[result.Winner]++
+	s.reasons[result.Reason]++
+	s.rounds += int(result.Round) + 1
+	s.cards += int(revealed.Neutral) + int(revealed.Good) + int(revealed.Bad) + int(revealed.Special)
+}
+
+func (s *stats) row() []string {
+	percent := func(n int) string {
+		return strconv.FormatFloat(100*float64(n)/float64(s.games), 'f', 1, 64)
+	}
+	average := func(n int) string {
+		return strconv.FormatFloat(float64(n)/float64(s.games), 'f', 2, 64)
+	}
+	return []string{
+		s.rules,
+		strconv.Itoa(s.players),
+		strconv.Itoa(s.games),
+		percent(s.wins[game.RoleGood]),
+		percent(s.wins[game.RoleBad]),
+		average(s.rounds),
+		average(s.cards),
+		percent(s.reasons[game.EndGoodRevealed]),
+		percent(s.reasons[game.EndBadRevealed]),
+		percent(s.reasons[game.EndRoundsExhausted]),
+	}
+}
+
+// strategies decide the moves of good and bad players by name, see bot.NewStrategy.
+type strategies struct {
+	good, bad string
+}
+
+// play runs one game of players by rules to its end.
+func play(rules *game.Ruleset, players int, s strategies, rng *rand.Rand) (game.Game, error) {
+	g, err := rules.NewGame(players, rand.NewSource(rng.Int63()))
+	if err != nil {
+		return g, err
+	}
+	bots := make([]bot.Strategy, players)
+	for i := range bots {
+		good, _ := bot.NewStrategy(s.good, rand.NewSource(rng.Int63()))
+		bad, _ := bot.NewStrategy(s.bad, rand.NewSource(rng.Int63()))
+		bots[i] = bot.ByRole{Good: good, Bad: bad}
+	}
+	for {
+		if _, ended := g.Result(); ended {
+			return g, nil
+		}
+		if g.State() == game.StatePlaying {
+			from := g.KeyHolder()
+			to := bots[from].Play(g.ViewOf(from))
+			err = g.Play(from, to)
+			if err != nil {
+				return g, err
+			}
+			continue
+		}
+		for p := game.Player(0); p < game.Player(players); p++ {
+			v := g.ViewOf(p)
+			if v.Claims[p] != nil || v.IsSilenced() {
+				continue
+			}
+			err = g.Claim(p, bots[p].Claim(v))
+			if err != nil {
+				return g, err
+			}
+		}
+	}
+}
+
+// simulate plays games for every player count rules have a setup for.
+func simulate(rules *game.Ruleset, games int, s strategies, rng *rand.Rand) ([]*stats, error) {
+	var all []*stats
+	for _, setup := range rules.Setups {
+		players := setup.Players
+		st := &stats{rules: rules.Name, players: players, wins: map[game.Role]int{}, reasons: map[game.EndReason]int{}}
+		for i := 0; i < games; i++ {
+			g, err := play(rules, players, s, rng)
+			if err != nil {
+				return nil, fmt.Errorf("%s with %d players: %w", rules.Name, players, err)
+			}
+			result, _ := g.Result()
+			st.add(result, g.RevealedCards)
+		}
+		all = append(all, st)
+	}
+	return all, nil
+}
+
+// rulesets returns the rules of all variants and every json file in dir.
+func rulesets(dir string) ([]*game.Ruleset, error) {
+	all := []*game.Ruleset{game.DefaultRuleset}
+	seen := map[string]bool{game.DefaultRuleset.Name: true}
+	for _, v := range game.Variants {
+		if !seen[v.Rules.Name] {
+			seen[v.Rules.Name] = true
+			all = append(all, v.Rules)
+		}
+	}
+	if dir == "" {
+		return all, nil
+	}
+	loaded, err := game.LoadRulesets(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rules := range loaded {
+		if seen[rules.Name] {
+			return nil, fmt.Errorf("ruleset %s already exists", rules.Name)
+		}
+		seen[rules.Name] = true
+		all = append(all, rules)
+	}
+	return all, nil
+}
+
+func write(w io.Writer, all []*stats, asCSV bool) error {
+	if asCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, s := range all {
+			cw.Write(s.row())
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	line := func(cells []string) {
+		for _, cell := range cells {
+			fmt.Fprint(tw, cell, "\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	line(header)
+	for _, s := range all {
+		line(s.row())
+	}
+	return tw.Flush()
+}
+
+func main() {
+	games := flag.Int("games", 1000, "games to play per ruleset and player count")
+	seed := flag.Int64("seed", 0, "seed of all games, 0 for a random seed")
+	good := flag.String("good", "honest", "strategy of good players, one of bot.Strategies")
+	bad := flag.String("bad", "deceptive", "strategy of bad players, one of bot.Strategies")
+	only := flag.String("ruleset", "", "only simulate the ruleset with this name")
+	dir := flag.String("rulesets", "", "directory of json rulesets to simulate besides the built in ones")
+	asCSV := flag.Bool("csv", false, "write CSV instead of a table")
+	flag.Parse()
+
+	for _, name := range []string{*good, *bad} {
+		if _, ok := bot.NewStrategy(name, nil); !ok || name == "" {
+			log.Fatalf("unknown strategy %q, must be one of %v", name, bot.Strategies)
+		}
+	}
+	if *games < 1 {
+		log.Fatalf("games must be positive, got %d", *games)
+	}
+	if *seed == 0 {
+		*seed = rand.New(game.NewCryptoSource()).Int63()
+		log.Printf("seed %d", *seed)
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	all, err := rulesets(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var results []*stats
+	for _, rules := range all {
+		if *only != "" && rules.Name != *only {
+			continue
+		}
+		s, err := simulate(rules, *games, strategies{*good, *bad}, rng)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, s...)
+	}
+	if len(results) == 0 {
+		log.Fatalf("no ruleset called %q", *only)
+	}
+	err = write(os.Stdout, results, *asCSV)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+package game
+
+import "fmt"
+
+// ErrorCode is the rule a move broke.
+// It is an error itself, so errors.Is(err, ErrClaimCount) works for every *Error.
+type ErrorCode uint8
+
+const (
+	// ErrWrongState the move is not allowed in the current State
+	ErrWrongState ErrorCode = iota
+	// ErrNotKeyHolder only the key holder may play
+	ErrNotKeyHolder
+	// ErrUnknownPlayer the player is not part of the game
+	ErrUnknownPlayer
+	// ErrOwnCards the key holder may not reveal their own cards
+	ErrOwnCards
+	// ErrEmptyHand the target has no cards left
+	ErrEmptyHand
+	// ErrClaimCount a claim must name as many cards as the hand holds
+	ErrClaimCount
+	// ErrClaimLocked claims can't be changed, see Game.LockClaims
+	ErrClaimLocked
+	// ErrSilenced the player may not claim this round, see EffectSilence
+	ErrSilenced
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrWrongState:
+		return "WrongState"
+	case ErrNotKeyHolder:
+		return "NotKeyHolder"
+	case ErrUnknownPlayer:
+		return "UnknownPlayer"
+	case ErrOwnCards:
+		return "OwnCards"
+	case ErrEmptyHand:
+		return "EmptyHand"
+	case ErrClaimCount:
+		return "ClaimCount"
+	case ErrClaimLocked:
+		return "ClaimLocked"
+	case ErrSilenced:
+		return "Silenced"
+	}
+	return fmt.Sprintf("ErrorCode(%d)", c)
+}
+
+func (c ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c ErrorCode) Error() string {
+	return c.String()
+}
+
+// Error is returned for every move that breaks the rules.
+type Error struct {
+	Code   ErrorCode
+	Player Player
+	msg    string
+}
+
+func newError(code ErrorCode, player Player, format string, a ...interface{}) *Error {
+	return &Error{code, player, fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("player: %d %s", e.Player, e.msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Code
+}
@@ -0,0 +1,144 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+/*
+Every change of a Game is recorded as Event. Deals and drawn cards are part of
+the events, so Replay rebuilds a Game without the rand.Source it was played with.
+Events hold the roles and hands of all players, don't show them before the game ended.
+*/
+
+type EventKind uint8
+
+const (
+	// EventStart the roles were drawn
+	EventStart EventKind = iota
+	// EventDeal every player got a new hand
+	EventDeal
+	EventClaim
+	// EventPlay Player revealed Card from the hand of To
+	EventPlay
+	// EventRoundEnd all players revealed a card, the claims are reset
+	EventRoundEnd
+	// EventEnd the game ended with Result
+	EventEnd
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStart:
+		return "Start"
+	case EventDeal:
+		return "Deal"
+	case EventClaim:
+		return "Claim"
+	case EventPlay:
+		return "Play"
+	case EventRoundEnd:
+		return "RoundEnd"
+	case EventEnd:
+		return "End"
+	}
+	return fmt.Sprintf("EventKind(%d)", k)
+}
+
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *EventKind) UnmarshalText(text []byte) error {
+	return unmarshalText(k, text, EventStart, EventDeal, EventClaim, EventPlay, EventRoundEnd, EventEnd)
+}
+
+// Event is one change of a Game, only the fields of its Kind are set.
+type Event struct {
+	Kind   EventKind   `json:"kind"`
+	Round  uint8       `json:"round"`
+	Player Player      `json:"player,omitempty"`
+	To     Player      `json:"to,omitempty"`
+	Card   Card        `json:"card,omitempty"`
+	Cards  *Cards      `json:"cards,omitempty"`
+	Roles  []Role      `json:"roles,omitempty"`
+	Hands  []Cards     `json:"hands,omitempty"`
+	Result *GameResult `json:"result,omitempty"`
+}
+
+var errReplay = errors.New("events don't fit the rules")
+
+func (g *Game) record(e Event) {
+	g.events = append(g.events, e)
+}
+
+// Events returns all changes of the Game so far, oldest first.
+func (g *Game) Events() []Event {
+	return append([]Event{}, g.events...)
+}
+
+// Replay rebuilds the Game recorded in events by the rules it was played with.
+// Further moves draw from src, if src is nil NewCryptoSource is used.
+func Replay(rules *Ruleset, events []Event, src rand.Source) (Game, error) {
+	var g Game
+	if len(events) == 0 || events[0].Kind != EventStart {
+		return g, fmt.Errorf("%w: first event must be %v", errReplay, EventStart)
+	}
+	players := len(events[0].Roles)
+	setup, ok := rules.setup(Player(players))
+	if !ok {
+		return g, fmt.Errorf("%w: no setup for %d players", errReplay, players)
+	}
+	if src == nil {
+		src = NewCryptoSource()
+	}
+	g.init(rules, setup, src)
+	for i, e := range events {
+		err := g.apply(e)
+		if err != nil {
+			return g, fmt.Errorf("%w: event %d: %v", errReplay, i, err)
+		}
+	}
+	if len(g.events) != len(events) {
+		return g, fmt.Errorf("%w: recorded %d events, replayed %d", errReplay, len(events), len(g.events))
+	}
+	return g, nil
+}
+
+// apply repeats e, events following from it like EventRoundEnd are recorded again.
+func (g *Game) apply(e Event) error {
+	switch e.Kind {
+	case EventStart:
+		if len(e.Roles) != int(g.playerCount) {
+			return fmt.Errorf("%d roles for %d players", len(e.Roles), g.playerCount)
+		}
+		g.Roles = append([]Role{}, e.Roles...)
+		g.record(Event{Kind: EventStart, Roles: g.Roles})
+	case EventDeal:
+		if len(e.Hands) != int(g.playerCount) {
+			return fmt.Errorf("%d hands for %d players", len(e.Hands), g.playerCount)
+		}
+		copy(g.Hands, e.Hands)
+		g.record(Event{Kind: EventDeal, Round: g.round(), Hands: append([]Cards{}, g.Hands...)})
+	case EventClaim:
+		if e.Cards == nil {
+			return errors.New("claim without cards")
+		}
+		return g.Claim(e.Player, *e.Cards)
+	case EventPlay:
+		err := g.checkPlay(e.Player, e.To)
+		if err != nil {
+			return err
+		}
+		if !g.Hands[e.To].remove(e.Card) {
+			return fmt.Errorf("player: %d has no card %v", e.To, e.Card)
+		}
+		g.reveal(e.Player, e.To, e.Card)
+	case EventRoundEnd, EventEnd:
+		// recorded by reveal
+	default:
+		return fmt.Errorf("unknown event %v", e.Kind)
+	}
+	return nil
+}
@@ -5,24 +5,96 @@ import (
 	"math/rand"
 )
 
+// unmarshalText sets *v to the one of values named text by its String method.
+func unmarshalText[T fmt.Stringer](v *T, text []byte, values ...T) error {
+	for _, value := range values {
+		if value.String() == string(text) {
+			*v = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %T: %s", *v, text)
+}
+
 type Card uint8
 
 const (
 	CardNeutral Card = iota
 	CardGood
 	CardBad
+	// CardSpecial does what Ruleset.Special says when revealed
+	CardSpecial
 )
 
+func (c Card) String() string {
+	switch c {
+	case CardNeutral:
+		return "Neutral"
+	case CardGood:
+		return "Good"
+	case CardBad:
+		return "Bad"
+	case CardSpecial:
+		return "Special"
+	}
+	return fmt.Sprintf("Card(%d)", c)
+}
+
+func (c Card) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Card) UnmarshalText(text []byte) error {
+	return unmarshalText(c, text, CardNeutral, CardGood, CardBad, CardSpecial)
+}
+
 type Cards struct {
-	Neutral, Good, Bad uint8
+	Neutral uint8 `json:"neutral"`
+	Good    uint8 `json:"good"`
+	Bad     uint8 `json:"bad"`
+	Special uint8 `json:"special"`
 }
 
-func (c *Cards) sum() uint8 {
-	return c.Neutral + c.Good + c.Bad
+// sum is an int, so the counts can't wrap around.
+func (c *Cards) sum() int {
+	return int(c.Neutral) + int(c.Good) + int(c.Bad) + int(c.Special)
+}
+
+// remove takes card out of c, false if there is none.
+func (c *Cards) remove(card Card) bool {
+	var count *uint8
+	switch card {
+	case CardNeutral:
+		count = &c.Neutral
+	case CardGood:
+		count = &c.Good
+	case CardBad:
+		count = &c.Bad
+	case CardSpecial:
+		count = &c.Special
+	}
+	if count == nil || *count == 0 {
+		return false
+	}
+	*count--
+	return true
+}
+
+func (c *Cards) add(card Card) {
+	switch card {
+	case CardNeutral:
+		c.Neutral++
+	case CardGood:
+		c.Good++
+	case CardBad:
+		c.Bad++
+	case CardSpecial:
+		c.Special++
+	}
 }
 
-func (c *Cards) draw() Card {
-	num := uint8(rand.Int31n(int32(c.sum())))
+func (c *Cards) draw(rng *rand.Rand) Card {
+	num := uint8(rng.Int31n(int32(c.sum())))
 	switch {
 	case num < c.Neutral:
 		c.Neutral--
@@ -30,9 +102,12 @@ func (c *Cards) draw() Card {
 	case num < c.Neutral+c.Good:
 		c.Good--
 		return CardGood
-	default:
+	case num < c.Neutral+c.Good+c.Bad:
 		c.Bad--
 		return CardBad
+	default:
+		c.Special--
+		return CardSpecial
 	}
 }
 
@@ -43,38 +118,35 @@ const (
 	RoleBad
 )
 
-type Roles struct {
-	Good, Bad uint8
+func (r Role) String() string {
+	switch r {
+	case RoleGood:
+		return "Good"
+	case RoleBad:
+		return "Bad"
+	}
+	return fmt.Sprintf("Role(%d)", r)
 }
 
-func roleDeck(players Player) Roles {
-	switch players {
-	case 3:
-		return Roles{2, 2}
-	case 4:
-		return Roles{3, 2}
-	case 5:
-		return Roles{3, 2}
-	case 6:
-		return Roles{4, 2}
-	case 7:
-		return Roles{5, 3}
-	case 8:
-		return Roles{6, 3}
-	case 9:
-		return Roles{6, 3}
-	case 10:
-		return Roles{7, 4}
-	}
-	panic("unreachable")
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
 }
 
-func (r *Roles) sum() uint8 {
-	return r.Good + r.Bad
+func (r *Role) UnmarshalText(text []byte) error {
+	return unmarshalText(r, text, RoleGood, RoleBad)
 }
 
-func (r *Roles) draw() Role {
-	num := rand.Int31n(int32(r.sum()))
+type Roles struct {
+	Good uint8 `json:"good"`
+	Bad  uint8 `json:"bad"`
+}
+
+func (r *Roles) sum() int {
+	return int(r.Good) + int(r.Bad)
+}
+
+func (r *Roles) draw(rng *rand.Rand) Role {
+	num := rng.Int31n(int32(r.sum()))
 	if uint8(num) < r.Good {
 		r.Good--
 		return RoleGood
@@ -83,28 +155,6 @@ func (r *Roles) draw() Role {
 	return RoleBad
 }
 
-func cardDeck(players Player) Cards {
-	switch players {
-	case 3:
-		return Cards{8, 5, 2}
-	case 4:
-		return Cards{12, 6, 2}
-	case 5:
-		return Cards{16, 7, 2}
-	case 6:
-		return Cards{20, 8, 2}
-	case 7:
-		return Cards{26, 7, 2}
-	case 8:
-		return Cards{30, 8, 2}
-	case 9:
-		return Cards{34, 9, 2}
-	case 10:
-		return Cards{37, 10, 3}
-	}
-	panic("unreachable")
-}
-
 type State uint8
 
 const (
@@ -114,6 +164,70 @@ const (
 	StateWinBad
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClaiming:
+		return "Claiming"
+	case StatePlaying:
+		return "Playing"
+	case StateWinGood:
+		return "WinGood"
+	case StateWinBad:
+		return "WinBad"
+	}
+	return fmt.Sprintf("State(%d)", s)
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	return unmarshalText(s, text, StateClaiming, StatePlaying, StateWinGood, StateWinBad)
+}
+
+// EndReason tells why a game ended.
+type EndReason uint8
+
+const (
+	// EndGoodRevealed all good cards were found, good wins
+	EndGoodRevealed EndReason = iota
+	// EndBadRevealed all bad cards were found, bad wins
+	EndBadRevealed
+	// EndRoundsExhausted the last round ended before all good cards were found, bad wins
+	EndRoundsExhausted
+)
+
+func (r EndReason) String() string {
+	switch r {
+	case EndGoodRevealed:
+		return "GoodRevealed"
+	case EndBadRevealed:
+		return "BadRevealed"
+	case EndRoundsExhausted:
+		return "RoundsExhausted"
+	}
+	return fmt.Sprintf("EndReason(%d)", r)
+}
+
+func (r EndReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *EndReason) UnmarshalText(text []byte) error {
+	return unmarshalText(r, text, EndGoodRevealed, EndBadRevealed, EndRoundsExhausted)
+}
+
+// GameResult explains how a finished game ended.
+type GameResult struct {
+	Winner Role      `json:"winner"`
+	Reason EndReason `json:"reason"`
+	// Round the game ended in, starting at 0
+	Round uint8 `json:"round"`
+	// Roles of all players, they are no secret once the game ended
+	Roles []Role `json:"roles"`
+}
+
 type Player uint8
 
 type Game struct {
@@ -123,104 +237,205 @@ type Game struct {
 	Hands         []Cards
 	Roles         []Role
 	Claims        []*Cards
+	// LockClaims forbids changing a claim once it was made
+	LockClaims bool
+	rules      *Ruleset
+	// setup of rules for playerCount
+	setup Setup
+	// rng is only used by the go routine owning the Game, it is not safe for concurrent use
+	rng *rand.Rand
+	// knownRoles were revealed by EffectRevealRole
+	knownRoles []bool
+	// silencedIn is the round a player may not claim in, see EffectSilence
+	silencedIn []uint8
+	events     []Event
 }
 
-func NewGame(players int) (Game, error) {
-	var g Game
-	if players < 3 || 10 < players {
-		return g, fmt.Errorf("invalid player count: %d, must be 3-10", players)
-	}
-	g.playerCount = Player(players)
-	g.Claims = make([]*Cards, players)
-	g.Hands = make([]Cards, players)
-	roles := roleDeck(g.playerCount)
-	for i := Player(0); i < g.playerCount; i++ {
-		g.Roles = append(g.Roles, roles.draw())
-	}
-	g.deal()
-	return g, nil
+// NewGame deals roles and cards for players by the DefaultRuleset from src.
+// If src is nil NewCryptoSource is used.
+func NewGame(players int, src rand.Source) (Game, error) {
+	return DefaultRuleset.NewGame(players, src)
 }
 
+// Claim publishes what player says they hold.
+// Claims may lie about the cards, but not about how many there are.
 func (g *Game) Claim(player Player, claim Cards) error {
 	if s := g.State(); s != StateClaiming {
-		return fmt.Errorf("palyer: %d tried to claim in State %v", player, s)
+		return newError(ErrWrongState, player, "tried to claim in State %v", s)
+	}
+	if player >= g.playerCount {
+		return newError(ErrUnknownPlayer, player, "tried to claim, but there are only %d players", g.playerCount)
+	}
+	if g.silenced(player) {
+		return newError(ErrSilenced, player, "tried to claim while silenced")
+	}
+	if g.LockClaims && g.Claims[player] != nil {
+		return newError(ErrClaimLocked, player, "tried to change claim %+v", *g.Claims[player])
+	}
+	n := g.Hands[player].sum()
+	for _, count := range []uint8{claim.Neutral, claim.Good, claim.Bad, claim.Special} {
+		if int(count) > n {
+			return newError(ErrClaimCount, player, "claimed %d cards of a kind, but holds %d", count, n)
+		}
+	}
+	if claim.sum() != n {
+		return newError(ErrClaimCount, player, "claimed %d cards, but holds %d", claim.sum(), n)
 	}
 	g.Claims[player] = &claim
+	g.record(Event{Kind: EventClaim, Round: g.round(), Player: player, Cards: &claim})
 	return nil
 }
 
+// Play lets from reveal a random card from the hand of to.
+// Only the key holder may play, to becomes the next key holder.
 func (g *Game) Play(from, to Player) error {
-	if s := g.State(); s != StatePlaying {
-		return fmt.Errorf("player: %d tried to play in State %v", from, s)
+	err := g.checkPlay(from, to)
+	if err != nil {
+		return err
 	}
-	if g.currentPlayer != from {
-		return fmt.Errorf("player: %d tried to play, but currentPlayer is: %d", from, g.currentPlayer)
+	card := g.Hands[to].draw(g.rng)
+	if g.reveal(from, to, card) {
+		g.deal()
 	}
+	return nil
+}
+
+// reveal shows card drawn by from from the hand of to.
+// Returns true if the round ended, the next hands must be dealt then.
+func (g *Game) reveal(from, to Player, card Card) bool {
+	round := g.round()
 	g.currentPlayer = to
-	card := g.Hands[to].draw()
-	switch card {
-	case CardNeutral:
-		g.RevealedCards.Neutral++
-	case CardGood:
-		g.RevealedCards.Good++
-	case CardBad:
-		g.RevealedCards.Bad++
+	g.RevealedCards.add(card)
+	if card == CardSpecial {
+		g.special(to)
+	}
+	g.record(Event{Kind: EventPlay, Round: round, Player: from, To: to, Card: card})
+	if result, ended := g.Result(); ended {
+		// no more rounds to deal
+		g.record(Event{Kind: EventEnd, Round: round, Result: &result})
+		return false
 	}
 	if g.cardsPlayedInRound() != 0 {
-		return nil
+		return false
 	}
 	// round ended
 	for p := Player(0); p < g.playerCount; p++ {
 		g.Claims[p] = nil
 	}
-	g.deal()
+	g.record(Event{Kind: EventRoundEnd, Round: round})
+	return true
+}
+
+func (g *Game) checkPlay(from, to Player) error {
+	if s := g.State(); s != StatePlaying {
+		return newError(ErrWrongState, from, "tried to play in State %v", s)
+	}
+	if g.currentPlayer != from {
+		return newError(ErrNotKeyHolder, from, "tried to play, but currentPlayer is: %d", g.currentPlayer)
+	}
+	if to >= g.playerCount {
+		return newError(ErrUnknownPlayer, from, "tried to play player: %d, but there are only %d players", to, g.playerCount)
+	}
+	if to == from {
+		return newError(ErrOwnCards, from, "tried to play own cards")
+	}
+	if g.Hands[to].sum() == 0 {
+		return newError(ErrEmptyHand, from, "tried to play player: %d, who has no cards left", to)
+	}
 	return nil
 }
 
+// Targets are the players the key holder may reveal a card of.
+// Outside of StatePlaying there are none.
+func (g *Game) Targets() []Player {
+	var targets []Player
+	if g.State() != StatePlaying {
+		return targets
+	}
+	for p := Player(0); p < g.playerCount; p++ {
+		if g.checkPlay(g.currentPlayer, p) == nil {
+			targets = append(targets, p)
+		}
+	}
+	return targets
+}
+
 func (g *Game) deal() {
-	deck := cardDeck(g.playerCount)
+	deck := g.setup.Cards
 	deck.Neutral -= g.RevealedCards.Neutral
 	deck.Good -= g.RevealedCards.Good
 	deck.Bad -= g.RevealedCards.Bad
-	toDraw := 5 - g.round()
+	deck.Special -= g.RevealedCards.Special
+	toDraw := g.rules.HandSize - g.round()
 	for p := Player(0); p < g.playerCount; p++ {
 		g.Hands[p] = Cards{}
-		cards := &g.Hands[p]
 		for i := uint8(0); i < toDraw; i++ {
-			card := deck.draw()
-			switch card {
-			case CardNeutral:
-				cards.Neutral++
-			case CardGood:
-				cards.Good++
-			case CardBad:
-				cards.Bad++
-			}
+			g.Hands[p].add(deck.draw(g.rng))
 		}
 	}
+	g.record(Event{Kind: EventDeal, Round: g.round(), Hands: append([]Cards{}, g.Hands...)})
 }
 
 func (g *Game) round() uint8 {
-	return g.RevealedCards.sum() / uint8(g.playerCount)
+	return uint8(g.RevealedCards.sum() / int(g.playerCount))
 }
 
 func (g *Game) cardsPlayedInRound() uint8 {
-	return g.RevealedCards.sum() % uint8(g.playerCount)
+	return uint8(g.RevealedCards.sum() % int(g.playerCount))
 }
 
-func (g *Game) State() State {
-	deck := cardDeck(g.playerCount)
-	if g.RevealedCards.Bad == deck.Bad {
-		return StateWinBad
+// end checks if the game is over.
+// Revealed cards are taken out of the deck before each deal, so
+// RevealedCards is compared with the full deck of the game.
+func (g *Game) end() (EndReason, bool) {
+	good, bad := g.rules.GoodToWin, g.rules.BadToWin
+	if good == 0 {
+		good = g.setup.Cards.Good
+	}
+	if bad == 0 {
+		bad = g.setup.Cards.Bad
+	}
+	if g.RevealedCards.Bad >= bad {
+		return EndBadRevealed, true
+	}
+	if g.RevealedCards.Good >= good {
+		return EndGoodRevealed, true
 	}
-	if g.RevealedCards.Good == deck.Good {
-		return StateWinGood
+	if g.round() == g.rules.Rounds {
+		return EndRoundsExhausted, true
+	}
+	return 0, false
+}
+
+// Result returns how the game ended, ok is false while it is running.
+func (g *Game) Result() (result GameResult, ok bool) {
+	reason, ended := g.end()
+	if !ended {
+		return result, false
 	}
-	if g.round() == 4 {
+	result.Reason = reason
+	result.Winner = RoleBad
+	if reason == EndGoodRevealed {
+		result.Winner = RoleGood
+	}
+	result.Round = g.round()
+	if g.cardsPlayedInRound() == 0 {
+		// the last card of a round ended the game
+		result.Round--
+	}
+	result.Roles = append([]Role{}, g.Roles...)
+	return result, true
+}
+
+func (g *Game) State() State {
+	if reason, ended := g.end(); ended {
+		if reason == EndGoodRevealed {
+			return StateWinGood
+		}
 		return StateWinBad
 	}
-	for _, c := range g.Claims {
-		if c == nil {
+	for p, c := range g.Claims {
+		if c == nil && !g.silenced(Player(p)) {
 			return StateClaiming
 		}
 	}
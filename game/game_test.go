@@ -1,37 +1,45 @@
 package game
 
 import (
+	"encoding/json"
+	"errors"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestRoleDraw(t *testing.T) {
-	deck := roleDeck(4)
-	good := uint8(0)
-	bad := uint8(0)
-	for deck.sum() > 0 {
-		role := deck.draw()
-		switch role {
-		case RoleGood:
-			good++
-		case RoleBad:
-			bad++
+	rng := rand.New(rand.NewSource(42))
+	// more roles than fit in a uint8 sum
+	for _, full := range []Roles{defaultSetup(4).Roles, {Good: 250, Bad: 9}} {
+		deck := full
+		good := uint8(0)
+		bad := uint8(0)
+		for deck.sum() > 0 {
+			role := deck.draw(rng)
+			switch role {
+			case RoleGood:
+				good++
+			case RoleBad:
+				bad++
+			}
+		}
+		if full.Good != good {
+			t.Fatalf("expected to draw %d good cards, got: %d", full.Good, good)
+		}
+		if full.Bad != bad {
+			t.Fatalf("expected to draw %d bad cards, got: %d", full.Bad, bad)
 		}
-	}
-	deck = roleDeck(4)
-	if deck.Good != good {
-		t.Fatalf("expected to draw %d good cards, got: %d", deck.Good, good)
-	}
-	if deck.Bad != bad {
-		t.Fatalf("expected to draw %d bad cards, got: %d", deck.Bad, bad)
 	}
 }
 
 func TestCardDraw(t *testing.T) {
-	deck := cardDeck(4)
+	rng := rand.New(rand.NewSource(42))
+	deck := defaultSetup(4).Cards
 	var draws Cards
 	for deck.sum() > 0 {
-		card := deck.draw()
+		card := deck.draw(rng)
 		switch card {
 		case CardNeutral:
 			draws.Neutral++
@@ -41,7 +49,7 @@ func TestCardDraw(t *testing.T) {
 			draws.Bad++
 		}
 	}
-	deck = cardDeck(4)
+	deck = defaultSetup(4).Cards
 	if deck != draws {
 		t.Fatalf("expected: %+v, got: %+v", deck, draws)
 	}
@@ -49,25 +57,200 @@ func TestCardDraw(t *testing.T) {
 
 func TestNewGame(t *testing.T) {
 	for i := 3; i <= 10; i++ {
-		g, err := NewGame(i)
+		g, err := NewGame(i, nil)
 		if err != nil {
 			t.Fatalf("expected game to be created without error, got: %v", err)
 		}
 		(&testGame{g, t}).invariants()
 	}
-	_, err := NewGame(2)
+	_, err := NewGame(2, nil)
 	if err == nil {
 		t.Fatal("expected game to error with less than 3 players")
 	}
-	_, err = NewGame(11)
+	_, err = NewGame(11, nil)
 	if err == nil {
 		t.Fatal("expected game to error with more than 10 players")
 	}
 }
 
+func TestRuleset(t *testing.T) {
+	err := DefaultRuleset.Validate()
+	if err != nil {
+		t.Fatalf("expected default ruleset to be valid, got: %v", err)
+	}
+	rules, err := LoadRuleset(strings.NewReader(`{
+		"name": "Short",
+		"setups": [{"players": 4, "roles": {"good": 3, "bad": 2}, "cards": {"neutral": 10, "good": 4, "bad": 2}}],
+		"handSize": 4,
+		"rounds": 3,
+		"badToWin": 1
+	}`))
+	if err != nil {
+		t.Fatalf("expected ruleset to load, got: %v", err)
+	}
+	if rules.MinPlayers() != 4 || rules.MaxPlayers() != 4 {
+		t.Fatalf("expected ruleset for 4 players only, got: %+v", rules)
+	}
+	if _, err := rules.NewGame(5, nil); err == nil {
+		t.Fatal("expected game with player count without setup to be rejected")
+	}
+	game, err := rules.NewGame(4, rand.NewSource(42))
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g := testGame{game, t}
+	g.invariants()
+	if g.Hands[0].sum() != 4 {
+		t.Fatalf("expected hands of 4 cards, got: %+v", g.Hands[0])
+	}
+	g.claimTruth()
+	g.Hands[1] = Cards{0, 0, 4, 0}
+	if err := g.Play(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := g.Result(); result.Reason != EndBadRevealed {
+		t.Fatalf("expected one bad card to end the game, got: %+v", result)
+	}
+
+	for _, invalid := range []string{
+		`{"name": "NoSetups", "handSize": 5, "rounds": 4}`,
+		`{"name": "Unknown", "field": 1}`,
+		`{"name": "Rounds", "setups": [{"players": 3, "roles": {"good": 2, "bad": 2}, "cards": {"neutral": 8, "good": 5, "bad": 2}}], "handSize": 5, "rounds": 6}`,
+		`{"name": "Cards", "setups": [{"players": 3, "roles": {"good": 2, "bad": 2}, "cards": {"neutral": 8, "good": 5, "bad": 1}}], "handSize": 5, "rounds": 4}`,
+		`{"name": "Roles", "setups": [{"players": 3, "roles": {"good": 2, "bad": 0}, "cards": {"neutral": 8, "good": 5, "bad": 2}}], "handSize": 5, "rounds": 4}`,
+		// the sum wraps around to 50 in a uint8
+		`{"name": "CardsWrap", "setups": [{"players": 10, "roles": {"good": 7, "bad": 4}, "cards": {"neutral": 200, "good": 100, "bad": 6}}], "handSize": 5, "rounds": 4}`,
+	} {
+		if _, err := LoadRuleset(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected ruleset to be rejected: %s", invalid)
+		}
+	}
+}
+
+func TestLoadRulesets(t *testing.T) {
+	all, err := LoadRulesets("../rulesets")
+	if err != nil || len(all) != 1 || all[0].Name != "Short" {
+		t.Fatalf("expected the Short ruleset, got: %v %v", all, err)
+	}
+	if _, err := LoadRulesets("../templates"); err != nil {
+		t.Fatalf("expected directory without rulesets to load none, got: %v", err)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	for _, v := range Variants {
+		if err := v.Rules.Validate(); err != nil {
+			t.Fatalf("expected rules of %s to be valid, got: %v", v.Name, err)
+		}
+		if found, _ := VariantByName(v.Name); found != v {
+			t.Fatalf("expected to find variant %s by name", v.Name)
+		}
+	}
+	if v, _ := VariantByName(""); v != Timebomb {
+		t.Fatalf("expected Timebomb to be the default, got: %s", v.Name)
+	}
+	if Cthulhu.Card(CardSpecial).Name != "Necronomicon" || Cthulhu.Role(RoleBad).Name != "Cultist" {
+		t.Fatal("expected Cthulhu to name its cards and roles")
+	}
+}
+
+func TestSpecialCards(t *testing.T) {
+	game, err := CthulhuRuleset.NewGame(4, rand.NewSource(42))
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g := testGame{game, t}
+	g.claimTruth()
+	g.Hands[1] = Cards{0, 0, 0, 1}
+	if err := g.Play(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	v := g.Public()
+	if v.KnownRoles[1] == nil || *v.KnownRoles[1] != g.Roles[1] || v.KnownRoles[0] != nil {
+		t.Fatalf("expected necronomicon to reveal role of player 1 only, got: %v", v.KnownRoles)
+	}
+
+	game, err = withSpecial(DefaultRuleset, "Silence", EffectSilence).NewGame(4, rand.NewSource(42))
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g = testGame{game, t}
+	g.claimTruth()
+	g.Hands[0] = Cards{5, 0, 0, 0}
+	g.Hands[1] = Cards{0, 0, 0, 1}
+	g.Hands[2] = Cards{5, 0, 0, 0}
+	for _, p := range [][2]Player{{0, 1}, {1, 2}, {2, 0}, {0, 2}} {
+		if err := g.Play(p[0], p[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.invariants()
+	if err := g.Claim(1, g.Hands[1]); !errors.Is(err, ErrSilenced) {
+		t.Fatalf("expected silenced player to not claim, got: %v", err)
+	}
+	for _, p := range []Player{0, 2, 3} {
+		g.tClaim(p, g.Hands[p])
+	}
+	if g.State() != StatePlaying || len(g.Public().Silenced) != 1 {
+		t.Fatalf("expected game to go on without claim of silenced player, got: %v %+v", g.State(), g.Public())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		game, err := CthulhuRuleset.NewGame(5, rand.NewSource(seed))
+		if err != nil {
+			t.Fatalf("could not create game: %v", err)
+		}
+		g := testGame{game, t}
+		g.playToEnd(rand.New(rand.NewSource(seed)))
+
+		bs, err := json.Marshal(g.Events())
+		if err != nil {
+			t.Fatalf("could not encode events: %v", err)
+		}
+		var events []Event
+		err = json.Unmarshal(bs, &events)
+		if err != nil {
+			t.Fatalf("could not decode events: %v", err)
+		}
+		replayed, err := Replay(CthulhuRuleset, events, nil)
+		if err != nil {
+			t.Fatalf("could not replay seed %d: %v", seed, err)
+		}
+		if !reflect.DeepEqual(replayed.Public(), g.Public()) || !reflect.DeepEqual(replayed.Events(), g.Events()) {
+			t.Fatalf("expected replay of seed %d to equal game, got: %+v, want: %+v", seed, replayed.Public(), g.Public())
+		}
+	}
+
+	var unknown []Event
+	if err := json.Unmarshal([]byte(`[{"kind":"Play","card":"Joker"}]`), &unknown); err == nil {
+		t.Fatalf("expected unknown card to be rejected, got: %+v", unknown)
+	}
+
+	game, _ := NewGame(4, rand.NewSource(42))
+	g := testGame{game, t}
+	g.claimTruth()
+	events := g.Events()
+	events[len(events)-1].Cards = &Cards{5, 0, 0, 0}
+	events = append(events, Event{Kind: EventPlay, Player: 0, To: 1, Card: CardSpecial})
+	if _, err := Replay(DefaultRuleset, events, nil); !errors.Is(err, errReplay) {
+		t.Fatalf("expected replay of impossible events to fail, got: %v", err)
+	}
+}
+
+func TestSeededGamesAreEqual(t *testing.T) {
+	a, _ := NewGame(7, rand.NewSource(7))
+	b, _ := NewGame(7, rand.NewSource(7))
+	for p := range a.Hands {
+		if a.Hands[p] != b.Hands[p] || a.Roles[p] != b.Roles[p] {
+			t.Fatalf("expected games with same seed to deal the same, got: %+v and %+v", a, b)
+		}
+	}
+}
+
 func TestHappyPathGoodWin(t *testing.T) {
-	rand.Seed(42)
-	game, err := NewGame(4)
+	game, err := NewGame(4, rand.NewSource(42))
 	if err != nil {
 		t.Fatalf("could not create game: %v", err)
 	}
@@ -75,91 +258,91 @@ func TestHappyPathGoodWin(t *testing.T) {
 	g.claimTruth()
 	// manipulate Hands to get some known State
 	// 4 player deck: 12, 6, 2
-	g.Hands[0] = Cards{0, 5, 0}
-	g.Hands[1] = Cards{5, 0, 0}
-	g.Hands[2] = Cards{2, 1, 2}
-	g.Hands[3] = Cards{5, 0, 0}
+	g.Hands[0] = Cards{0, 5, 0, 0}
+	g.Hands[1] = Cards{5, 0, 0, 0}
+	g.Hands[2] = Cards{2, 1, 2, 0}
+	g.Hands[3] = Cards{5, 0, 0, 0}
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.claimTruth()
 	// deck: 10, 4, 2
-	g.Hands[0] = Cards{0, 4, 0}
-	g.Hands[1] = Cards{4, 0, 0}
-	g.Hands[2] = Cards{4, 0, 0}
-	g.Hands[3] = Cards{2, 0, 2}
+	g.Hands[0] = Cards{0, 4, 0, 0}
+	g.Hands[1] = Cards{4, 0, 0, 0}
+	g.Hands[2] = Cards{4, 0, 0, 0}
+	g.Hands[3] = Cards{2, 0, 2, 0}
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.claimTruth()
 	// deck: 8, 2, 2
-	g.Hands[0] = Cards{1, 2, 0}
-	g.Hands[1] = Cards{3, 0, 0}
-	g.Hands[2] = Cards{3, 0, 0}
-	g.Hands[3] = Cards{1, 0, 2}
+	g.Hands[0] = Cards{1, 2, 0, 0}
+	g.Hands[1] = Cards{3, 0, 0, 0}
+	g.Hands[2] = Cards{3, 0, 0, 0}
+	g.Hands[3] = Cards{1, 0, 2, 0}
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	g.claimTruth()
 	// deck: 5, 1, 2
-	g.Hands[0] = Cards{1, 1, 0}
-	g.Hands[1] = Cards{2, 0, 0}
-	g.Hands[2] = Cards{2, 0, 0}
-	g.Hands[3] = Cards{0, 0, 2}
+	g.Hands[0] = Cards{1, 1, 0, 0}
+	g.Hands[1] = Cards{2, 0, 0, 0}
+	g.Hands[2] = Cards{2, 0, 0, 0}
+	g.Hands[3] = Cards{0, 0, 2, 0}
 	g.tPlay(0, 1)
 	g.tPlay(1, 0)
 	if g.State() != StateWinGood {
 		t.Fatal("expected good to win")
 	}
+	g.ensureResult(RoleGood, EndGoodRevealed, 3)
 }
 
 func TestHappyPathBadWinBadCards(t *testing.T) {
-	rand.Seed(42)
-	game, err := NewGame(4)
+	game, err := NewGame(4, rand.NewSource(42))
 	if err != nil {
 		t.Fatalf("could not create game: %v", err)
 	}
 	g := testGame{game, t}
 	g.claimTruth()
 	// 4 player deck: 12, 6, 2
-	g.Hands[0] = Cards{0, 5, 0}
-	g.Hands[1] = Cards{5, 0, 0}
-	g.Hands[2] = Cards{2, 1, 2}
-	g.Hands[3] = Cards{5, 0, 0}
+	g.Hands[0] = Cards{0, 5, 0, 0}
+	g.Hands[1] = Cards{5, 0, 0, 0}
+	g.Hands[2] = Cards{2, 1, 2, 0}
+	g.Hands[3] = Cards{5, 0, 0, 0}
 	g.tPlay(0, 2)
 	g.tPlay(2, 0)
 	g.tPlay(0, 2)
 	g.tPlay(2, 0)
 	g.claimTruth()
 	// deck: 12, 3, 1
-	g.Hands[0] = Cards{1, 3, 0}
-	g.Hands[1] = Cards{4, 0, 0}
-	g.Hands[2] = Cards{3, 0, 1}
-	g.Hands[3] = Cards{4, 0, 0}
+	g.Hands[0] = Cards{1, 3, 0, 0}
+	g.Hands[1] = Cards{4, 0, 0, 0}
+	g.Hands[2] = Cards{3, 0, 1, 0}
+	g.Hands[3] = Cards{4, 0, 0, 0}
 	g.tPlay(0, 2)
 	g.tPlay(2, 0)
 	g.tPlay(0, 2)
 	g.tPlay(2, 0)
 	g.claimTruth()
 	// deck: 9, 2, 1
-	g.Hands[0] = Cards{1, 2, 0}
-	g.Hands[1] = Cards{3, 0, 0}
-	g.Hands[2] = Cards{2, 0, 1}
-	g.Hands[3] = Cards{3, 0, 0}
+	g.Hands[0] = Cards{1, 2, 0, 0}
+	g.Hands[1] = Cards{3, 0, 0, 0}
+	g.Hands[2] = Cards{2, 0, 1, 0}
+	g.Hands[3] = Cards{3, 0, 0, 0}
 	g.tPlay(0, 2)
 	g.tPlay(2, 0)
 	g.tPlay(0, 2)
 	if g.State() != StateWinBad || g.RevealedCards.Bad != 2 {
 		t.Fatal("expected bad to win by discovering 2 bad cards")
 	}
+	g.ensureResult(RoleBad, EndBadRevealed, 2)
 }
 
 func TestHappyPathBadWinTurnsExhausted(t *testing.T) {
-	rand.Seed(42)
-	game, err := NewGame(4)
+	game, err := NewGame(4, rand.NewSource(42))
 	if err != nil {
 		t.Fatalf("could not create game: %v", err)
 	}
@@ -167,40 +350,40 @@ func TestHappyPathBadWinTurnsExhausted(t *testing.T) {
 	g.currentPlayer = 1
 	g.claimTruth()
 	// 4 player deck: 12, 6, 2
-	g.Hands[0] = Cards{0, 5, 0}
-	g.Hands[1] = Cards{5, 0, 0}
-	g.Hands[2] = Cards{2, 1, 2}
-	g.Hands[3] = Cards{5, 0, 0}
+	g.Hands[0] = Cards{0, 5, 0, 0}
+	g.Hands[1] = Cards{5, 0, 0, 0}
+	g.Hands[2] = Cards{2, 1, 2, 0}
+	g.Hands[3] = Cards{5, 0, 0, 0}
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.claimTruth()
 	// deck: 8, 6, 2
-	g.Hands[0] = Cards{0, 4, 0}
-	g.Hands[1] = Cards{4, 0, 0}
-	g.Hands[2] = Cards{0, 2, 2}
-	g.Hands[3] = Cards{4, 0, 0}
+	g.Hands[0] = Cards{0, 4, 0, 0}
+	g.Hands[1] = Cards{4, 0, 0, 0}
+	g.Hands[2] = Cards{0, 2, 2, 0}
+	g.Hands[3] = Cards{4, 0, 0, 0}
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.claimTruth()
 	// deck: 4, 6, 2
-	g.Hands[0] = Cards{0, 2, 1}
-	g.Hands[1] = Cards{2, 1, 0}
-	g.Hands[2] = Cards{0, 2, 1}
-	g.Hands[3] = Cards{2, 1, 0}
+	g.Hands[0] = Cards{0, 2, 1, 0}
+	g.Hands[1] = Cards{2, 1, 0, 0}
+	g.Hands[2] = Cards{0, 2, 1, 0}
+	g.Hands[3] = Cards{2, 1, 0, 0}
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.claimTruth()
 	// deck: 0, 6, 2
-	g.Hands[0] = Cards{0, 1, 1}
-	g.Hands[1] = Cards{1, 1, 0}
-	g.Hands[2] = Cards{0, 1, 1}
-	g.Hands[3] = Cards{0, 2, 0}
+	g.Hands[0] = Cards{0, 1, 1, 0}
+	g.Hands[1] = Cards{1, 1, 0, 0}
+	g.Hands[2] = Cards{0, 1, 1, 0}
+	g.Hands[3] = Cards{0, 2, 0, 0}
 	g.tPlay(1, 3)
 	g.tPlay(3, 1)
 	g.tPlay(1, 3)
@@ -208,6 +391,150 @@ func TestHappyPathBadWinTurnsExhausted(t *testing.T) {
 	if g.State() != StateWinBad || g.RevealedCards.Good == 6 {
 		t.Fatalf("expected bad to win by playing 4 turns without finding all good cards, got %v", g.State())
 	}
+	g.ensureResult(RoleBad, EndRoundsExhausted, 3)
+}
+
+func TestPlayTargets(t *testing.T) {
+	game, err := NewGame(4, nil)
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g := testGame{game, t}
+	if _, ok := g.Result(); ok {
+		t.Fatal("expected running game to have no result")
+	}
+	if len(g.Targets()) != 0 {
+		t.Fatalf("expected no targets while claiming, got: %v", g.Targets())
+	}
+	g.claimTruth()
+	g.Hands[2] = Cards{}
+	g.Hands[3] = Cards{}
+	if targets := g.Targets(); len(targets) != 1 || targets[0] != 1 {
+		t.Fatalf("expected only player 1 to be a target, got: %v", targets)
+	}
+	for _, to := range []Player{0, 2, 4} {
+		if err := g.Play(0, to); err == nil {
+			t.Fatalf("expected play of player %d to be rejected", to)
+		}
+	}
+	if err := g.Play(1, 0); err == nil {
+		t.Fatal("expected play of player who isn't key holder to be rejected")
+	}
+}
+
+func TestClaimValidation(t *testing.T) {
+	game, err := NewGame(4, nil)
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g := testGame{game, t}
+	if err := g.Claim(0, Cards{1, 1, 1, 0}); !errors.Is(err, ErrClaimCount) {
+		t.Fatalf("expected claim of 3 cards with 5 in hand to be rejected, got: %v", err)
+	}
+	// 255 + 6 wraps around to 5 in a uint8
+	if err := g.Claim(0, Cards{Neutral: 255, Good: 6}); !errors.Is(err, ErrClaimCount) {
+		t.Fatalf("expected claim of 261 cards with 5 in hand to be rejected, got: %v", err)
+	}
+	if err := g.Claim(4, Cards{5, 0, 0, 0}); !errors.Is(err, ErrUnknownPlayer) {
+		t.Fatalf("expected claim of unknown player to be rejected, got: %v", err)
+	}
+	// lies are fine as long as the count matches
+	g.tClaim(0, Cards{0, 0, 5, 0})
+	g.tClaim(0, Cards{5, 0, 0, 0})
+	g.LockClaims = true
+	var gameErr *Error
+	if err := g.Claim(0, Cards{0, 5, 0, 0}); !errors.As(err, &gameErr) || gameErr.Code != ErrClaimLocked || gameErr.Player != 0 {
+		t.Fatalf("expected locked claim to be rejected, got: %v", err)
+	}
+	g.LockClaims = false
+	g.claimTruth()
+	if err := g.Play(0, 0); !errors.Is(err, ErrOwnCards) {
+		t.Fatalf("expected play of own cards to be rejected, got: %v", err)
+	}
+	if err := g.Claim(1, g.Hands[1]); !errors.Is(err, ErrWrongState) {
+		t.Fatalf("expected claim while playing to be rejected, got: %v", err)
+	}
+}
+
+func TestView(t *testing.T) {
+	game, err := NewGame(4, nil)
+	if err != nil {
+		t.Fatalf("could not create game: %v", err)
+	}
+	g := testGame{game, t}
+	g.tClaim(2, Cards{1, 2, 2, 0})
+	for p := Player(0); p < 4; p++ {
+		v := g.ViewOf(p)
+		if v.Seat != p || v.Hand != g.Hands[p] || v.Role != g.Roles[p] {
+			t.Fatalf("expected view of player: %d to contain own hand and role, got: %+v", p, v)
+		}
+		if v.KeyHolder != 0 || v.State != StateClaiming || v.Claims[2] == nil {
+			t.Fatalf("expected view to contain public state, got: %+v", v)
+		}
+		v.Claims[2].Bad = 0
+	}
+	if g.Claims[2].Bad != 2 {
+		t.Fatal("expected view to not share claims with game")
+	}
+}
+
+func defaultSetup(players Player) Setup {
+	s, _ := DefaultRuleset.setup(players)
+	return s
+}
+
+// fuzzRulesets cover every Effect and a last round of single cards.
+var fuzzRulesets = []*Ruleset{DefaultRuleset, CthulhuRuleset, withSpecial(DefaultRuleset, "Silence", EffectSilence), allRounds(DefaultRuleset)}
+
+// allRounds copies base, the game goes on until every card was revealed.
+func allRounds(base *Ruleset) *Ruleset {
+	r := *base
+	r.Name = "AllRounds"
+	r.Rounds = r.HandSize
+	return &r
+}
+
+// FuzzGame drives a game with arbitrary moves, legal or not.
+// Every three bytes of moves are one move, see testGame.move.
+func FuzzGame(f *testing.F) {
+	f.Add(uint8(1), int64(42), []byte{0, 0, 0, 0, 1, 0, 0, 2, 0, 0, 3, 0, 2, 1, 0})
+	f.Add(uint8(7), int64(7), []byte{1, 200, 255, 3, 11, 11, 2, 255, 0})
+	f.Add(uint8(0), int64(1), []byte(strings.Repeat("\x00\x00\x01\x00\x01\x01\x00\x02\x02\x02\x01\x00\x02\x02\x00", 40)))
+	f.Fuzz(func(t *testing.T, players uint8, seed int64, moves []byte) {
+		rules := fuzzRulesets[uint64(seed)%uint64(len(fuzzRulesets))]
+		game, err := rules.NewGame(3+int(players%8), rand.NewSource(seed))
+		if err != nil {
+			t.Fatalf("could not create game: %v", err)
+		}
+		g := testGame{game, t}
+		g.invariants()
+		for ; len(moves) >= 3; moves = moves[3:] {
+			g.move(moves[0], moves[1], moves[2])
+		}
+	})
+}
+
+// TestRandomGames mixes random moves, mostly legal, with illegal ones and then plays to the end.
+func TestRandomGames(t *testing.T) {
+	for _, rules := range fuzzRulesets {
+		for players := 3; players <= 10; players++ {
+			for seed := int64(0); seed < 20; seed++ {
+				game, err := rules.NewGame(players, rand.NewSource(seed))
+				if err != nil {
+					t.Fatalf("could not create game: %v", err)
+				}
+				g := testGame{game, t}
+				rng := rand.New(rand.NewSource(seed))
+				for i := 0; i < 100; i++ {
+					g.move(byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256)))
+				}
+				g.playToEnd(rng)
+				if _, ok := g.Result(); !ok {
+					t.Fatalf("expected %s with %d players and seed %d to end", rules.Name, players, seed)
+				}
+			}
+		}
+	}
 }
 
 type testGame struct {
@@ -248,6 +575,92 @@ func (g *testGame) claimTruth() {
 	}
 }
 
+// playToEnd claims the truth for everyone who didn't claim yet and plays random targets until the game ended.
+func (g *testGame) playToEnd(rng *rand.Rand) {
+	g.Helper()
+	for {
+		switch g.State() {
+		case StateClaiming:
+			for p := Player(0); p < g.playerCount; p++ {
+				if !g.silenced(p) && g.Claims[p] == nil {
+					g.tClaim(p, g.Hands[p])
+				}
+			}
+		case StatePlaying:
+			targets := g.Targets()
+			g.tPlay(g.KeyHolder(), targets[rng.Intn(len(targets))])
+		default:
+			return
+		}
+	}
+}
+
+// move makes one move chosen by op, a and b are players or cards depending on op.
+// Rejected moves must not change the game, accepted ones only move it forward.
+func (g *testGame) move(op, a, b byte) {
+	g.Helper()
+	before := g.Public()
+	events := len(g.events)
+	var err error
+	switch op % 4 {
+	case 0:
+		// a claim of the right size
+		p := Player(a) % g.playerCount
+		n := uint8(g.Hands[p].sum())
+		good := b % (n + 1)
+		bad := b / 16 % (n - good + 1)
+		err = g.Claim(p, Cards{n - good - bad, good, bad, 0})
+	case 1:
+		err = g.Claim(Player(a%12), Cards{b % 8, b / 8 % 4, b / 32 % 4, b / 128})
+	case 2:
+		err = g.Play(g.KeyHolder(), Player(a%12))
+	default:
+		err = g.Play(Player(a%12), Player(b%12))
+	}
+	after := g.Public()
+	if err != nil {
+		var gameErr *Error
+		if !errors.As(err, &gameErr) {
+			g.Fatalf("expected rejected move to return *Error, got: %v", err)
+		}
+		if !reflect.DeepEqual(before, after) || len(g.events) != events {
+			g.Fatalf("expected rejected move %d to change nothing, got: %+v, want: %+v", op%4, after, before)
+		}
+		return
+	}
+	if before.Result != nil {
+		g.Fatalf("expected no moves after the end, got: %d %d %d", op, a, b)
+	}
+	if progress(after) < progress(before) {
+		g.Fatalf("expected game to move forward, went from round %d %v to round %d %v", before.Round, before.State, after.Round, after.State)
+	}
+	if revealed := after.Revealed.sum() - before.Revealed.sum(); revealed > 1 || (revealed == 1) != (op%4 >= 2) {
+		g.Fatalf("expected only plays to reveal one card, move %d revealed %d", op%4, revealed)
+	}
+	g.invariants()
+}
+
+// progress orders the states of a game, it never decreases.
+func progress(v PublicView) int {
+	return int(v.Round)*4 + int(v.State)
+}
+
+func (g *testGame) ensureResult(winner Role, reason EndReason, round uint8) {
+	g.Helper()
+	result, ok := g.Result()
+	if !ok {
+		g.Fatal("expected game to have a result")
+	}
+	if result.Winner != winner || result.Reason != reason || result.Round != round {
+		g.Fatalf("expected %v to win by %v in round %d, got: %+v", winner, reason, round, result)
+	}
+	for p, role := range g.Roles {
+		if result.Roles[p] != role {
+			g.Fatalf("expected result to reveal role of player %d: %v, got: %v", p, role, result.Roles[p])
+		}
+	}
+}
+
 func (g *testGame) invariants() {
 	g.Helper()
 	g.ensureSizes()
@@ -279,8 +692,14 @@ func (g *testGame) ensureCards() {
 		sum.Neutral += hand.Neutral
 		sum.Good += hand.Good
 		sum.Bad += hand.Bad
+		sum.Special += hand.Special
+	}
+	deck := g.setup.Cards
+	for _, hand := range g.Hands {
+		if hand.Neutral > deck.Neutral || hand.Good > deck.Good || hand.Bad > deck.Bad || hand.Special > deck.Special {
+			g.Errorf("expected hand: %+v to hold no more cards than the deck: %+v", hand, deck)
+		}
 	}
-	deck := cardDeck(g.playerCount)
 	if sum != deck {
 		g.Errorf("expected all cards: %v to be somewhere, got: %v", deck, sum)
 	}
@@ -291,6 +710,10 @@ func (g *testGame) ensureHandSize() {
 	if g.cardsPlayedInRound() != 0 {
 		return
 	}
+	if _, ended := g.end(); ended {
+		// the last hands are not dealt
+		return
+	}
 	for p := Player(1); p < g.playerCount; p++ {
 		if g.Hands[p-1].sum() != g.Hands[p].sum() {
 			g.Errorf(
@@ -0,0 +1,34 @@
+package game
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+)
+
+// cryptoSource is a rand.Source reading from crypto/rand.
+// It can't be seeded, shuffles are unpredictable.
+type cryptoSource struct{}
+
+// NewCryptoSource is the default source of NewGame, use it in production.
+// Use rand.NewSource with a fixed seed to reproduce a game, e.g. in tests.
+func NewCryptoSource() rand.Source64 {
+	return cryptoSource{}
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var bs [8]byte
+	_, err := crand.Read(bs[:])
+	if err != nil {
+		// crypto/rand only fails if the os has no randomness left
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(bs[:])
+}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// Seed is a no-op, cryptoSource can't be seeded.
+func (cryptoSource) Seed(int64) {}
@@ -0,0 +1,196 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Setup is what is dealt for one player count.
+type Setup struct {
+	Players int `json:"players"`
+	// Roles may hold more roles than players, the rest stays hidden
+	Roles Roles `json:"roles"`
+	// Cards must fill every hand, Players * Ruleset.HandSize
+	Cards Cards `json:"cards"`
+}
+
+// Ruleset holds everything that can be tweaked without changing the game itself.
+type Ruleset struct {
+	Name   string  `json:"name"`
+	Setups []Setup `json:"setups"`
+	// HandSize is dealt in the first round, every round one card less
+	HandSize uint8 `json:"handSize"`
+	// Rounds is how many rounds are played at most, then bad wins
+	Rounds uint8 `json:"rounds"`
+	// GoodToWin is how many good cards good needs to reveal, 0 means all
+	GoodToWin uint8 `json:"goodToWin"`
+	// BadToWin is how many bad cards bad needs to be revealed, 0 means all
+	BadToWin uint8 `json:"badToWin"`
+	// Special is the Effect of CardSpecial, only needed if Setups deal special cards
+	Special Effect `json:"special"`
+}
+
+// DefaultRuleset are the rules of the original game for 3-10 players.
+var DefaultRuleset = &Ruleset{
+	Name: "Classic",
+	Setups: []Setup{
+		{3, Roles{2, 2}, Cards{8, 5, 2, 0}},
+		{4, Roles{3, 2}, Cards{12, 6, 2, 0}},
+		{5, Roles{3, 2}, Cards{16, 7, 2, 0}},
+		{6, Roles{4, 2}, Cards{20, 8, 2, 0}},
+		{7, Roles{5, 3}, Cards{26, 7, 2, 0}},
+		{8, Roles{6, 3}, Cards{30, 8, 2, 0}},
+		{9, Roles{6, 3}, Cards{34, 9, 2, 0}},
+		{10, Roles{7, 4}, Cards{37, 10, 3, 0}},
+	},
+	HandSize: 5,
+	Rounds:   4,
+}
+
+// LoadRuleset reads a Ruleset from JSON and validates it.
+func LoadRuleset(r io.Reader) (*Ruleset, error) {
+	var rules Ruleset
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&rules)
+	if err != nil {
+		return nil, fmt.Errorf("decoding ruleset: %v", err)
+	}
+	err = rules.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// LoadRulesets reads every json file in dir as Ruleset, see LoadRuleset.
+func LoadRulesets(dir string) ([]*Ruleset, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	all := make([]*Ruleset, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		rules, err := LoadRuleset(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		all = append(all, rules)
+	}
+	return all, nil
+}
+
+// Validate checks that every Setup of r can be played to the end.
+func (r *Ruleset) Validate() error {
+	if r.Name == "" {
+		return errors.New("ruleset has no name")
+	}
+	if len(r.Setups) == 0 {
+		return fmt.Errorf("ruleset %s has no setups", r.Name)
+	}
+	if r.Rounds == 0 || r.Rounds > r.HandSize {
+		return fmt.Errorf("ruleset %s: rounds: %d must be 1 to hand size: %d", r.Name, r.Rounds, r.HandSize)
+	}
+	seen := map[int]bool{}
+	for _, s := range r.Setups {
+		if s.Players < 2 || s.Players > 255 {
+			return fmt.Errorf("ruleset %s: invalid player count: %d", r.Name, s.Players)
+		}
+		if seen[s.Players] {
+			return fmt.Errorf("ruleset %s: more than one setup for %d players", r.Name, s.Players)
+		}
+		seen[s.Players] = true
+		if s.Roles.Good == 0 || s.Roles.Bad == 0 || s.Roles.sum() < s.Players {
+			return fmt.Errorf("ruleset %s: roles: %+v don't fit %d players", r.Name, s.Roles, s.Players)
+		}
+		if s.Cards.sum() != s.Players*int(r.HandSize) {
+			return fmt.Errorf("ruleset %s: cards: %+v don't fill %d hands of %d", r.Name, s.Cards, s.Players, r.HandSize)
+		}
+		if s.Cards.Special > 0 && r.Special == EffectNone {
+			return fmt.Errorf("ruleset %s: cards: %+v contain special cards without effect", r.Name, s.Cards)
+		}
+		if s.Cards.Good == 0 || s.Cards.Bad == 0 || r.GoodToWin > s.Cards.Good || r.BadToWin > s.Cards.Bad {
+			return fmt.Errorf("ruleset %s: cards: %+v can't reach the win conditions", r.Name, s.Cards)
+		}
+	}
+	return nil
+}
+
+// MinPlayers is the smallest player count with a Setup.
+func (r *Ruleset) MinPlayers() int {
+	min := r.Setups[0].Players
+	for _, s := range r.Setups {
+		if s.Players < min {
+			min = s.Players
+		}
+	}
+	return min
+}
+
+// MaxPlayers is the largest player count with a Setup.
+func (r *Ruleset) MaxPlayers() int {
+	max := r.Setups[0].Players
+	for _, s := range r.Setups {
+		if s.Players > max {
+			max = s.Players
+		}
+	}
+	return max
+}
+
+func (r *Ruleset) setup(players Player) (Setup, bool) {
+	for _, s := range r.Setups {
+		if s.Players == int(players) {
+			return s, true
+		}
+	}
+	return Setup{}, false
+}
+
+// NewGame deals roles and cards for players by the rules of r from src.
+// If src is nil NewCryptoSource is used.
+func (r *Ruleset) NewGame(players int, src rand.Source) (Game, error) {
+	var g Game
+	setup, ok := r.setup(Player(players))
+	if !ok || players < 2 || players > 255 {
+		return g, fmt.Errorf("invalid player count: %d, must be %d-%d", players, r.MinPlayers(), r.MaxPlayers())
+	}
+	if src == nil {
+		src = NewCryptoSource()
+	}
+	g.init(r, setup, src)
+	roles := setup.Roles
+	for i := Player(0); i < g.playerCount; i++ {
+		g.Roles = append(g.Roles, roles.draw(g.rng))
+	}
+	g.record(Event{Kind: EventStart, Roles: append([]Role{}, g.Roles...)})
+	g.deal()
+	return g, nil
+}
+
+// Rules returns the Ruleset g is played by.
+func (g *Game) Rules() *Ruleset {
+	return g.rules
+}
+
+func (g *Game) init(r *Ruleset, setup Setup, src rand.Source) {
+	players := setup.Players
+	g.rules = r
+	g.setup = setup
+	g.rng = rand.New(src)
+	g.playerCount = Player(players)
+	g.Claims = make([]*Cards, players)
+	g.Hands = make([]Cards, players)
+	g.knownRoles = make([]bool, players)
+	g.silencedIn = make([]uint8, players)
+}
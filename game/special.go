@@ -0,0 +1,53 @@
+package game
+
+import "fmt"
+
+// Effect is what happens when a CardSpecial is revealed.
+// It always affects the player the card was revealed from.
+type Effect uint8
+
+const (
+	// EffectNone rulesets without special cards
+	EffectNone Effect = iota
+	// EffectRevealRole shows everyone the role of the player, like Cthulhu's Necronomicon
+	EffectRevealRole
+	// EffectSilence forbids the player to claim in the next round, like Insanity's Grasp
+	EffectSilence
+)
+
+func (e Effect) String() string {
+	switch e {
+	case EffectNone:
+		return "None"
+	case EffectRevealRole:
+		return "RevealRole"
+	case EffectSilence:
+		return "Silence"
+	}
+	return fmt.Sprintf("Effect(%d)", e)
+}
+
+func (e Effect) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *Effect) UnmarshalText(text []byte) error {
+	return unmarshalText(e, text, EffectNone, EffectRevealRole, EffectSilence)
+}
+
+// special applies the Effect of the rules to the player a CardSpecial was revealed from.
+func (g *Game) special(player Player) {
+	switch g.rules.Special {
+	case EffectRevealRole:
+		g.knownRoles[player] = true
+	case EffectSilence:
+		// the round after the one the card was revealed in
+		g.silencedIn[player] = uint8((g.RevealedCards.sum()-1)/int(g.playerCount) + 1)
+	}
+}
+
+// silenced players may not claim in this round.
+func (g *Game) silenced(player Player) bool {
+	round := g.round()
+	return round > 0 && g.silencedIn[player] == round
+}
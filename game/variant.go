@@ -0,0 +1,125 @@
+package game
+
+// Label is how a Variant calls a card or role, Art is the key of its artwork.
+type Label struct {
+	Name string `json:"name"`
+	Art  string `json:"art"`
+}
+
+type RoleLabels struct {
+	Good Label `json:"good"`
+	Bad  Label `json:"bad"`
+}
+
+type CardLabels struct {
+	Neutral Label `json:"neutral"`
+	Good    Label `json:"good"`
+	Bad     Label `json:"bad"`
+	Special Label `json:"special"`
+}
+
+// Variant is one of the published games, they all play like Game.
+// Besides names and artwork they differ in their Ruleset.
+type Variant struct {
+	Name  string     `json:"name"`
+	Rules *Ruleset   `json:"-"`
+	Roles RoleLabels `json:"roles"`
+	Cards CardLabels `json:"cards"`
+}
+
+// Role returns the Label of r.
+func (v *Variant) Role(r Role) Label {
+	if r == RoleBad {
+		return v.Roles.Bad
+	}
+	return v.Roles.Good
+}
+
+// Card returns the Label of c.
+func (v *Variant) Card(c Card) Label {
+	switch c {
+	case CardGood:
+		return v.Cards.Good
+	case CardBad:
+		return v.Cards.Bad
+	case CardSpecial:
+		return v.Cards.Special
+	}
+	return v.Cards.Neutral
+}
+
+// CthulhuRuleset is the DefaultRuleset with one neutral card replaced by the Necronomicon.
+var CthulhuRuleset = withSpecial(DefaultRuleset, "Cthulhu", EffectRevealRole)
+
+var (
+	Timebomb = &Variant{
+		Name:  "Timebomb",
+		Rules: DefaultRuleset,
+		Roles: RoleLabels{
+			Good: Label{"Sherlock", "timebomb/sherlock"},
+			Bad:  Label{"Moriarty", "timebomb/moriarty"},
+		},
+		Cards: CardLabels{
+			Neutral: Label{"Secure Wire", "timebomb/secure-wire"},
+			Good:    Label{"Defusing Wire", "timebomb/defusing-wire"},
+			Bad:     Label{"Big Ben", "timebomb/big-ben"},
+		},
+	}
+	Tempel = &Variant{
+		Name:  "Tempel des Schreckens",
+		Rules: DefaultRuleset,
+		Roles: RoleLabels{
+			Good: Label{"Abenteurer", "tempel/abenteurer"},
+			Bad:  Label{"Wächterin", "tempel/waechterin"},
+		},
+		Cards: CardLabels{
+			Neutral: Label{"Leere Kammer", "tempel/leere-kammer"},
+			Good:    Label{"Goldschatz", "tempel/goldschatz"},
+			Bad:     Label{"Feuerfalle", "tempel/feuerfalle"},
+		},
+	}
+	Cthulhu = &Variant{
+		Name:  "Don't Mess with Cthulhu",
+		Rules: CthulhuRuleset,
+		Roles: RoleLabels{
+			Good: Label{"Investigator", "cthulhu/investigator"},
+			Bad:  Label{"Cultist", "cthulhu/cultist"},
+		},
+		Cards: CardLabels{
+			Neutral: Label{"Futile", "cthulhu/futile"},
+			Good:    Label{"Elder Sign", "cthulhu/elder-sign"},
+			Bad:     Label{"Cthulhu", "cthulhu/cthulhu"},
+			Special: Label{"Necronomicon", "cthulhu/necronomicon"},
+		},
+	}
+)
+
+// Variants are all published games, the first is the default.
+var Variants = []*Variant{Timebomb, Tempel, Cthulhu}
+
+// VariantByName returns the Variant called name, empty is the default.
+func VariantByName(name string) (*Variant, bool) {
+	if name == "" {
+		return Variants[0], true
+	}
+	for _, v := range Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// withSpecial copies base, every Setup deals one special card instead of a neutral one.
+func withSpecial(base *Ruleset, name string, effect Effect) *Ruleset {
+	r := *base
+	r.Name = name
+	r.Special = effect
+	r.Setups = make([]Setup, len(base.Setups))
+	for i, s := range base.Setups {
+		s.Cards.Neutral--
+		s.Cards.Special++
+		r.Setups[i] = s
+	}
+	return &r
+}
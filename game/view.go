@@ -0,0 +1,90 @@
+package game
+
+// PublicView is the part of a Game every player may know.
+type PublicView struct {
+	Round    uint8 `json:"round"`
+	Revealed Cards `json:"revealed"`
+	// Cards are all cards of the game, revealed or not
+	Cards     Cards    `json:"cards"`
+	Claims    []*Cards `json:"claims"`
+	KeyHolder Player   `json:"keyHolder"`
+	// Targets the KeyHolder may reveal a card of
+	Targets []Player `json:"targets"`
+	State   State    `json:"state"`
+	// KnownRoles are the roles revealed by EffectRevealRole, nil if unknown
+	KnownRoles []*Role `json:"knownRoles"`
+	// Silenced players don't claim this round
+	Silenced []Player `json:"silenced"`
+	// Result is nil while the game is running
+	Result *GameResult `json:"result"`
+}
+
+// View is what a single player may know about a Game.
+// It never contains the Hands or Roles of other players.
+type View struct {
+	PublicView
+	Seat Player `json:"seat"`
+	Hand Cards  `json:"hand"`
+	Role Role   `json:"role"`
+}
+
+// IsSilenced tells if the player the view belongs to may not claim this round.
+func (v View) IsSilenced() bool {
+	for _, p := range v.Silenced {
+		if p == v.Seat {
+			return true
+		}
+	}
+	return false
+}
+
+// Round starts at 0, every round each player reveals one card.
+func (g *Game) Round() uint8 {
+	return g.round()
+}
+
+// KeyHolder is the player who reveals the next card.
+func (g *Game) KeyHolder() Player {
+	return g.currentPlayer
+}
+
+func (g *Game) Public() PublicView {
+	v := PublicView{
+		Round:     g.round(),
+		Revealed:  g.RevealedCards,
+		Cards:     g.setup.Cards,
+		Claims:    make([]*Cards, len(g.Claims)),
+		KeyHolder: g.currentPlayer,
+		Targets:   g.Targets(),
+		State:     g.State(),
+	}
+	for p, c := range g.Claims {
+		if c != nil {
+			claim := *c
+			v.Claims[p] = &claim
+		}
+	}
+	v.KnownRoles = make([]*Role, g.playerCount)
+	for p := Player(0); p < g.playerCount; p++ {
+		if g.knownRoles[p] {
+			role := g.Roles[p]
+			v.KnownRoles[p] = &role
+		}
+		if g.silenced(p) {
+			v.Silenced = append(v.Silenced, p)
+		}
+	}
+	if result, ok := g.Result(); ok {
+		v.Result = &result
+	}
+	return v
+}
+
+func (g *Game) ViewOf(player Player) View {
+	return View{
+		PublicView: g.Public(),
+		Seat:       player,
+		Hand:       g.Hands[player],
+		Role:       g.Roles[player],
+	}
+}
@@ -0,0 +1,112 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Players talk in the chat of their Lobby, spectators don't see it.
+Messages are kept as plain text, whoever renders them has to escape them.
+The last maxChatHistory messages are part of every PlayerView, so reconnecting
+players see what they missed. The host can mute players, see Mute.
+*/
+
+var (
+	ErrMuted          = errors.New("muted by host")
+	ErrRateLimited    = errors.New("too many chat messages")
+	ErrInvalidMessage = errors.New("invalid chat message")
+)
+
+// maxChatLength in characters.
+const maxChatLength = 280
+
+// maxChatHistory is how many messages a Lobby keeps.
+const maxChatHistory = 50
+
+// chatBurst messages each player may send within chatWindow.
+const (
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+// Chat sends text to all players.
+func Chat(lobby, player uint, token string, text string) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorize(player, token)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(text)
+		if text == "" || !utf8.ValidString(text) {
+			return ErrInvalidMessage
+		}
+		if n := utf8.RuneCountInString(text); n > maxChatLength {
+			return fmt.Errorf("%w: %d characters, at most %d", ErrInvalidMessage, n, maxChatLength)
+		}
+		if strings.IndexFunc(text, unicode.IsControl) >= 0 {
+			// e.g. a bare \r ends a line of the event stream
+			return fmt.Errorf("%w: control characters", ErrInvalidMessage)
+		}
+		p := &l.players[player]
+		if p.muted {
+			return ErrMuted
+		}
+		now := time.Now()
+		if len(p.chatted) == chatBurst && now.Sub(p.chatted[0]) < chatWindow {
+			return fmt.Errorf("%w: at most %d in %v", ErrRateLimited, chatBurst, chatWindow)
+		}
+		if len(p.chatted) == chatBurst {
+			p.chatted = p.chatted[1:]
+		}
+		p.chatted = append(p.chatted, now)
+		message := ChatMessage{Player: p.position, Name: p.Name, Text: text, At: now}
+		l.chat = append(l.chat, message)
+		if len(l.chat) > maxChatHistory {
+			l.chat = l.chat[len(l.chat)-maxChatHistory:]
+		}
+		l.acted()
+		l.broadcast(&message)
+		return nil
+	})
+}
+
+// GetChat returns the last messages of the chat.
+func GetChat(lobby, player uint, token string) ([]ChatMessage, error) {
+	var chat []ChatMessage
+	err := do(lobby, func(l *Lobby) error {
+		err := l.authorize(player, token)
+		if err != nil {
+			return err
+		}
+		chat = l.chatHistory()
+		return nil
+	})
+	return chat, err
+}
+
+// Mute lets the host forbid or allow player to chat.
+func Mute(lobby, host uint, token string, player uint, muted bool) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		if player == host || int(player) >= len(l.players) {
+			return fmt.Errorf("%w: can't mute seat %d", ErrInvalidAction, player)
+		}
+		l.players[player].muted = muted
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// chatHistory copies the chat, so it can leave the go routine of the Lobby.
+func (l *Lobby) chatHistory() []ChatMessage {
+	return append([]ChatMessage{}, l.chat...)
+}
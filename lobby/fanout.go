@@ -0,0 +1,133 @@
+package lobby
+
+import (
+	"sync"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// maxQueue is how many Messages a subscriber may lag behind before it is detached.
+const maxQueue = 32
+
+// flushTimeout is how long a finishing subscriber may take to receive its queue.
+const flushTimeout = 5 * time.Second
+
+// subscriber forwards the Messages of a Lobby to one registered channel.
+// send never blocks, so one slow client can't stall the Lobby.
+// When the subscriber is detached its channel is closed.
+type subscriber struct {
+	out   chan Message
+	mu    sync.Mutex
+	queue []Message
+	wake  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+	// finishing subscribers deliver their queue, then detach
+	finishing bool
+}
+
+func newSubscriber(out chan Message) *subscriber {
+	s := &subscriber{
+		out:  out,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go s.pump()
+	return s
+}
+
+// send queues message for delivery.
+// A snapshot replaces any queued Message of the same kind.
+// Returns false if the queue is full, the subscriber should be detached then.
+func (s *subscriber) send(message Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := message.(snapshot); ok {
+		for i, queued := range s.queue {
+			if queued.GetKind() == message.GetKind() {
+				// move to the end, so it stays ordered after earlier events
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(s.queue) == maxQueue {
+		return false
+	}
+	s.queue = append(s.queue, message)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (s *subscriber) pump() {
+	defer close(s.out)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				finishing := s.finishing
+				s.mu.Unlock()
+				if finishing {
+					return
+				}
+				break
+			}
+			message := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			select {
+			case s.out <- message:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+func (s *subscriber) detach() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// finish detaches the subscriber after its queue was delivered,
+// or after flushTimeout if the receiver doesn't keep up.
+func (s *subscriber) finish() {
+	s.mu.Lock()
+	s.finishing = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	time.AfterFunc(flushTimeout, s.detach)
+}
+
+// sendTo delivers message to player only, dead subscribers are detached.
+func (l *Lobby) sendTo(player game.Player, message Message) {
+	p := &l.players[player]
+	if p.sub == nil {
+		// player is not listening, e.g. an api client
+		return
+	}
+	if !p.sub.send(message) {
+		p.sub.detach()
+		p.sub = nil
+	}
+}
+
+// broadcast delivers message to every player.
+func (l *Lobby) broadcast(message Message) {
+	for _, p := range l.players {
+		l.sendTo(p.position, message)
+	}
+}
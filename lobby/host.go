@@ -0,0 +1,111 @@
+package lobby
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// authorizeHost is authorize for actions only the host may take.
+func (l *Lobby) authorizeHost(player uint, token string) error {
+	err := l.authorize(player, token)
+	if err != nil {
+		return err
+	}
+	if game.Player(player) != l.host {
+		return fmt.Errorf("%w: seat %d is not host", ErrUnauthorized, player)
+	}
+	return nil
+}
+
+// Kick removes player from the Lobby.
+// Players can only be kicked while no game is running.
+func Kick(lobby, host uint, token string, player uint) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		if l.running() {
+			return ErrStarted
+		}
+		if int(player) >= len(l.players) || player == host {
+			return fmt.Errorf("%w: can't kick seat %d", ErrInvalidAction, player)
+		}
+		l.removePlayer(game.Player(player), ReasonKicked)
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// TransferHost makes to the new host.
+func TransferHost(lobby, host uint, token string, to uint) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		if int(to) >= len(l.players) || l.players[to].vacant() {
+			return fmt.Errorf("%w: seat %d is not seated", ErrInvalidAction, to)
+		}
+		l.host = game.Player(to)
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// SetLocked stops or allows players joining.
+func SetLocked(lobby, host uint, token string, locked bool) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		l.locked = locked
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// SetOptions changes the Options of the next game.
+// The variant and ruleset name the cards of the game, so they can't change while it runs.
+func SetOptions(lobby, host uint, token string, options Options) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		err = options.validate()
+		if err != nil {
+			return err
+		}
+		if options.Variant != l.options.Variant || options.Ruleset != l.options.Ruleset {
+			if l.running() {
+				return fmt.Errorf("%w: can't change variant or ruleset", ErrStarted)
+			}
+			// a finished game would be shown by the new names
+			l.game = nil
+			l.finishedAt = time.Time{}
+		}
+		l.options = options
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// Disband closes the Lobby for everyone.
+func Disband(lobby, host uint, token string) error {
+	err := do(lobby, func(l *Lobby) error {
+		return l.authorizeHost(host, token)
+	})
+	if err != nil {
+		return err
+	}
+	Close(lobby)
+	return nil
+}
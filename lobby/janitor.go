@@ -0,0 +1,143 @@
+package lobby
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+type CloseReason uint8
+
+const (
+	// ReasonClosed is an explicit call to Close
+	ReasonClosed CloseReason = iota
+	ReasonIdle
+	ReasonFinished
+	ReasonAbandoned
+	// ReasonKicked is only sent to the player the host removed
+	ReasonKicked
+	// ReasonLeft is only sent to the player who left
+	ReasonLeft
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case ReasonClosed:
+		return "Closed"
+	case ReasonIdle:
+		return "Idle"
+	case ReasonFinished:
+		return "Finished"
+	case ReasonAbandoned:
+		return "Abandoned"
+	case ReasonKicked:
+		return "Kicked"
+	case ReasonLeft:
+		return "Left"
+	}
+	return fmt.Sprintf("CloseReason(%d)", r)
+}
+
+func (r CloseReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// JanitorConfig decides when lobbies are garbage collected.
+// A zero duration disables the corresponding check.
+type JanitorConfig struct {
+	// Interval between two sweeps, zero uses the one of DefaultJanitorConfig
+	Interval time.Duration
+	// IdleTTL is how long a Lobby may go without any player action
+	IdleTTL time.Duration
+	// FinishedGrace is how long a Lobby stays open after its game ended
+	FinishedGrace time.Duration
+	// AbandonedTTL is how long a Lobby stays open after its last player went away
+	AbandonedTTL time.Duration
+}
+
+var DefaultJanitorConfig = JanitorConfig{
+	Interval:      time.Minute,
+	IdleTTL:       2 * time.Hour,
+	FinishedGrace: 30 * time.Minute,
+	AbandonedTTL:  10 * time.Minute,
+}
+
+// StartJanitor closes expired lobbies until ctx is done.
+func StartJanitor(ctx context.Context, config JanitorConfig) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultJanitorConfig.Interval
+	}
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				sweep(now, config)
+			}
+		}
+	}()
+}
+
+// sweep closes all lobbies that are expired at now.
+func sweep(now time.Time, config JanitorConfig) {
+	lobbies.RLock()
+	ids := make([]uint, 0, len(lobbies.ls))
+	for id := range lobbies.ls {
+		ids = append(ids, id)
+	}
+	lobbies.RUnlock()
+
+	for _, id := range ids {
+		var reason CloseReason
+		expired := false
+		do(id, func(l *Lobby) error {
+			reason, expired = l.expired(now, config)
+			return nil
+		})
+		if expired {
+			closeLobby(id, reason)
+		}
+	}
+}
+
+// expired returns why l should be closed at now, if at all.
+func (l *Lobby) expired(now time.Time, config JanitorConfig) (CloseReason, bool) {
+	if config.FinishedGrace > 0 && !l.finishedAt.IsZero() && now.Sub(l.finishedAt) > config.FinishedGrace {
+		return ReasonFinished, true
+	}
+	var lastSeen time.Time
+	for _, p := range l.players {
+		if p.lastSeen.After(lastSeen) {
+			lastSeen = p.lastSeen
+		}
+	}
+	if config.AbandonedTTL > 0 && now.Sub(lastSeen) > awayAfter+config.AbandonedTTL {
+		return ReasonAbandoned, true
+	}
+	if config.IdleTTL > 0 && now.Sub(l.lastAction) > config.IdleTTL {
+		return ReasonIdle, true
+	}
+	return 0, false
+}
+
+// acted records a player action, so the Lobby doesn't count as idle.
+// Every action changes the Lobby, so it is saved afterwards.
+func (l *Lobby) acted() {
+	l.lastAction = time.Now()
+	l.dirty = true
+	if l.game == nil {
+		return
+	}
+	if s := l.game.State(); s == game.StateWinGood || s == game.StateWinBad {
+		if l.finishedAt.IsZero() {
+			l.finishedAt = l.lastAction
+		}
+	} else {
+		l.finishedAt = time.Time{}
+	}
+}
@@ -2,226 +2,769 @@ package lobby
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/c-goetz/traitor-card-game/game"
 )
 
 /*
 Manage the lobby, don't refer to http things here, just channels.
-For each lobby start a go routine to handle events and notify players.
-"Connection" to player needs the possibility to timeout (maybe sth. like 5 min?).
-Or is just timing out the lobby enough?
-One Player should be host. Hos should have special rights like removing players from lobby.
+Each lobby runs its own go routine, see run. All reads and mutations of a
+Lobby are sent there as commands, so they never race and every player
+receives messages in the same order. The exported functions do that, see do,
+methods of Lobby must only be called from its go routine.
+Exported functions taking a seat and a token only act if the token belongs to
+that seat, they return ErrUnauthorized otherwise.
+Players are marked away when they aren't seen for a while, see presence.go.
+They keep their seat and are back online with their next request.
+Players leaving while no game is running give up their seat, everyone behind
+moves one seat up, so position always equals the index in players.
+A finished game is dropped once the seats change, its seats don't fit anymore.
+Players leaving a running game leave their seat vacant, it keeps its position
+in the game. The game is paused until new players took all vacant seats.
+The first Player is host. Only the host may start the game, kick players,
+lock the Lobby, change its options, mute players and hand over hosting to
+another Player, see host.go. Those functions need the seat of the host.
 */
 
+var (
+	ErrLobbyNotFound = errors.New("lobby not found")
+	ErrLobbyFull     = errors.New("max lobby size reached")
+	ErrNameTaken     = errors.New("name already taken")
+	ErrUnauthorized  = errors.New("not authorized")
+	ErrNotStarted    = errors.New("game not started")
+	ErrStarted       = errors.New("game already started")
+	ErrInvalidName   = errors.New("invalid name")
+	ErrInvalidAction = errors.New("invalid action")
+	ErrLocked        = errors.New("lobby locked")
+	ErrPaused        = errors.New("game paused until vacant seats are taken")
+)
+
+// actionError is an ErrInvalidAction because a move broke the rules of the game.
+// errors.As finds the *game.Error with the code of the rule.
+type actionError struct {
+	err error
+}
+
+func (e actionError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrInvalidAction, e.err)
+}
+
+func (e actionError) Is(target error) bool {
+	return target == ErrInvalidAction
+}
+
+func (e actionError) Unwrap() error {
+	return e.err
+}
+
+// Options are chosen by the host, they apply from the next game on.
+type Options struct {
+	// LockClaims forbids changing a claim once it was made
+	LockClaims bool `json:"lockClaims"`
+	// Variant is the name of a game.Variant. Empty is the first of game.Variants
+	Variant string `json:"variant"`
+	// Ruleset is the name of a Ruleset, see AddRuleset. Empty is the Ruleset of the Variant
+	Ruleset string `json:"ruleset"`
+	// SpectatorDelay is how many seconds spectators lag behind, it applies at once
+	SpectatorDelay uint `json:"spectatorDelay"`
+}
+
+// validate checks that all names in options are known
+// and that the variant names every card the ruleset deals.
+func (o Options) validate() error {
+	variant, ok := game.VariantByName(o.Variant)
+	if !ok {
+		return fmt.Errorf("%w: unknown variant %s", ErrInvalidAction, o.Variant)
+	}
+	rules, ok := ruleset(o.Ruleset)
+	if !ok && o.Ruleset != "" {
+		return fmt.Errorf("%w: unknown ruleset %s", ErrInvalidAction, o.Ruleset)
+	}
+	if !ok {
+		rules = variant.Rules
+	}
+	for _, s := range rules.Setups {
+		if s.Cards.Special > 0 && variant.Cards.Special.Name == "" {
+			return fmt.Errorf("%w: %s has no special cards, ruleset %s deals them", ErrInvalidAction, variant.Name, rules.Name)
+		}
+	}
+	if o.SpectatorDelay > maxSpectatorDelay {
+		return fmt.Errorf("%w: spectator delay over %d seconds", ErrInvalidAction, maxSpectatorDelay)
+	}
+	return nil
+}
+
+// lobbies only guards the registry, the Lobby itself is owned by its go routine.
+// New lobbies are saved in store, see UseStore.
 var lobbies = struct {
 	sync.RWMutex
-	ls map[uint]*Lobby
+	ls    map[uint]*Lobby
+	store Store
 }{
 	ls: map[uint]*Lobby{},
 }
 
 type Player struct {
-	Name     string
+	Name string
+	// token is empty for vacant seats
 	token    string
 	lastSeen time.Time
-	channel  *chan Message
+	presence Presence
+	sub      *subscriber
 	position game.Player
+	// muted players may not chat, see Mute
+	muted bool
+	// chatted are the times of the last chat messages, see Chat
+	chatted []time.Time
+	// bot seats are played by a bot.Bot, see PlayAsBot
+	bot bool
 }
 
 type Lobby struct {
-	sync.RWMutex
 	game    *game.Game
 	Uuid    uint
 	players []Player
+	host    game.Player
+	// locked lobbies don't let new players join
+	locked     bool
+	options    Options
+	chat       []ChatMessage
+	lastAction time.Time
+	// finishedAt is zero while the game is not finished
+	finishedAt time.Time
+	// store is nil if lobbies are only kept in memory
+	store Store
+	// dirty lobbies changed since they were saved
+	dirty      bool
+	spectators []*spectator
+	// spectated are the SpectatorViews of the delay, see broadcastSpectators
+	spectated    []spectated
+	spectatorSeq uint64
+	commands     chan command
+	closing      chan CloseReason
+	closed       chan struct{}
 }
 
-func Register(lobby, player uint, channel *chan Message) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.Lock()
-	defer l.Unlock()
+type command struct {
+	fn     func(l *Lobby) error
+	result chan error
+}
 
-	l.players[player].channel = channel
+func newLobby(id uint) *Lobby {
+	return &Lobby{
+		Uuid:       id,
+		players:    []Player{},
+		lastAction: time.Now(),
+		commands:   make(chan command),
+		closing:    make(chan CloseReason, 1),
+		closed:     make(chan struct{}),
+	}
 }
 
-func UnregisterChannel(lobby, player uint) {
+// run executes the commands of the Lobby one after another until it is closed.
+func (l *Lobby) run() {
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case c := <-l.commands:
+			err := c.fn(l)
+			l.persist()
+			c.result <- err
+		case now := <-ticker.C:
+			l.updatePresence(now)
+		case reason := <-l.closing:
+			if l.store != nil {
+				err := l.store.Delete(l.Uuid)
+				if err != nil {
+					log.Printf("lobby %d: deleting: %v", l.Uuid, err)
+				}
+			}
+			l.broadcast(&ClosingMessage{Reason: reason})
+			for i := range l.players {
+				if l.players[i].sub != nil {
+					l.players[i].sub.finish()
+				}
+			}
+			l.closeSpectators(reason)
+			close(l.closed)
+			return
+		}
+	}
+}
+
+// do runs fn in the go routine of lobby and waits for it to return.
+func do(lobby uint, fn func(l *Lobby) error) error {
 	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.Lock()
-	defer l.Unlock()
+	l, ok := lobbies.ls[lobby]
+	lobbies.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %d", ErrLobbyNotFound, lobby)
+	}
+	c := command{fn, make(chan error, 1)}
+	select {
+	case l.commands <- c:
+		return <-c.result
+	case <-l.closed:
+		return fmt.Errorf("%w %d", ErrLobbyNotFound, lobby)
+	}
+}
 
-	l.players[player].channel = nil
+// Register attaches channel to the Player owning token, all Messages for them are sent there.
+// The seat is looked up by the Lobby, so it can't move in between.
+// The first Message is always a ViewMessage. An earlier channel of the Player is detached.
+// The Lobby closes channel when it detaches it, e.g. because the receiver
+// lags behind too far or the Lobby was closed.
+func Register(lobby uint, token string, channel *chan Message) error {
+	return do(lobby, func(l *Lobby) error {
+		p := l.owner(token)
+		if p == nil {
+			return fmt.Errorf("%w: unknown token", ErrUnauthorized)
+		}
+		if p.sub != nil {
+			p.sub.detach()
+		}
+		p.sub = newSubscriber(*channel)
+		l.touch(p.position)
+		l.sendTo(p.position, &ViewMessage{View: l.viewOf(p.position)})
+		return nil
+	})
 }
 
-func SetName(lobby, player uint, name string) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.Lock()
-	defer l.Unlock()
+// UnregisterChannel detaches channel from whoever registered it.
+// If the player registered another channel in the meantime nothing happens.
+// Players may have changed seats since Register, so channel is looked up.
+func UnregisterChannel(lobby uint, channel *chan Message) {
+	do(lobby, func(l *Lobby) error {
+		for i := range l.players {
+			p := &l.players[i]
+			if p.sub != nil && p.sub.out == *channel {
+				p.sub.detach()
+				p.sub = nil
+			}
+		}
+		return nil
+	})
+}
+
+// SetName renames player.
+func SetName(lobby, player uint, token string, name string) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorize(player, token)
+		if err != nil {
+			return err
+		}
+		name, err = validName(name)
+		if err != nil {
+			return err
+		}
+		for _, p := range l.players {
+			if p.Name == name && uint(p.position) != player {
+				return fmt.Errorf("%w: player with name %s already joined", ErrNameTaken, name)
+			}
+		}
+		l.players[player].Name = name
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// owns compares in constant time, so tokens can't be guessed by timing.
+func (p *Player) owns(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(p.token), []byte(token)) == 1
+}
 
-	l.players[player].Name = name
+// maxNameLength in characters.
+const maxNameLength = 32
+
+// validName returns name without surrounding spaces if it may be shown to everyone.
+// Control characters are rejected, e.g. a bare \r ends a line of the event stream.
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || !utf8.ValidString(name) {
+		return "", ErrInvalidName
+	}
+	if n := utf8.RuneCountInString(name); n > maxNameLength {
+		return "", fmt.Errorf("%w: %d characters, at most %d", ErrInvalidName, n, maxNameLength)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: control characters", ErrInvalidName)
+	}
+	return name, nil
 }
 
-func (l *Lobby) NewPlayer(name string, token string, channel *chan Message) Player {
-	return Player{name, token, time.Now(), channel, game.Player(len(l.players))}
+func (l *Lobby) NewPlayer(name string, token string) Player {
+	return Player{
+		Name:     name,
+		token:    token,
+		lastSeen: time.Now(),
+		presence: PresenceOnline,
+		position: game.Player(len(l.players)),
+	}
 }
 
 // CreateLobby Creates Lobby and Host
 // First player is always Host
-// Return Lobby number
-func CreateLobby(host string) (uint, error) {
-	lobbies.Lock()
-	lobbyUID, err := rand.Int(rand.Reader, big.NewInt(math.MaxUint32))
+// Return Lobby number and the token of the Host
+func CreateLobby(host string) (uint, string, error) {
+	return CreateLobbyWith(host, Options{})
+}
+
+// CreateLobbyWith is CreateLobby with the Options of the first game, e.g. its Variant.
+func CreateLobbyWith(host string, options Options) (uint, string, error) {
+	host, err := validName(host)
 	if err != nil {
-		return 0, fmt.Errorf("generating Lobby UID: %v", err)
+		return 0, "", err
 	}
-	id := uint(lobbyUID.Uint64())
-	lobby := Lobby{
-		sync.RWMutex{},
-		nil,
-		id,
-		[]Player{},
+	err = options.validate()
+	if err != nil {
+		return 0, "", err
 	}
-	lobbies.ls[id] = &lobby
+	lobbies.Lock()
+	var id uint
+	for {
+		lobbyUID, err := rand.Int(rand.Reader, big.NewInt(math.MaxUint32))
+		if err != nil {
+			lobbies.Unlock()
+			return 0, "", fmt.Errorf("generating Lobby UID: %v", err)
+		}
+		id = uint(lobbyUID.Uint64())
+		if _, ok := lobbies.ls[id]; !ok {
+			break
+		}
+	}
+	lobby := newLobby(id)
+	lobby.options = options
+	lobby.store = lobbies.store
+	go lobby.run()
+	lobbies.ls[id] = lobby
 	lobbies.Unlock()
-	_, err = Join(id, host)
+	_, token, err := Join(id, host)
 	if err != nil {
-		return 0, fmt.Errorf("could not create player with name: %v %s", host, err)
+		Close(id)
+		return 0, "", fmt.Errorf("could not create player with name: %v %w", host, err)
 	}
-	return id, nil
+	return id, token, nil
 }
 
-func Join(lobby uint, name string) (uint, error) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	if _, ok := lobbies.ls[lobby]; !ok {
-		return 0, fmt.Errorf("lobby not found %d", lobby)
+// vacant seats were left during a game and wait for a new Player.
+func (p *Player) vacant() bool {
+	return p.token == ""
+}
+
+// Join seats a new Player in the Lobby.
+// Once the game started new players can only take vacant seats.
+// Return position and token of the Player
+func Join(lobby uint, name string) (uint, string, error) {
+	var player Player
+	err := do(lobby, func(l *Lobby) error {
+		var err error
+		name, err = validName(name)
+		if err != nil {
+			return err
+		}
+		if l.locked {
+			return ErrLocked
+		}
+		seat := l.vacantSeat()
+		if l.running() && seat < 0 {
+			return ErrStarted
+		}
+		if n := len(l.players); n >= l.rules().MaxPlayers() && seat < 0 {
+			return ErrLobbyFull
+		}
+		for _, player := range l.players {
+			if player.Name == name && !player.vacant() {
+				return fmt.Errorf("%w: player with name %s already joined", ErrNameTaken, player.Name)
+			}
+		}
+		token, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+		if err != nil {
+			return fmt.Errorf("generating token: %v", err)
+		}
+		player = l.NewPlayer(name, token.String())
+		if seat < 0 {
+			l.players = append(l.players, player)
+			l.seatsChanged()
+		} else {
+			player.position = game.Player(seat)
+			l.players[seat] = player
+		}
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return uint(player.position), player.token, nil
+}
+
+// seated counts the seats that are not vacant.
+func (l *Lobby) seated() int {
+	n := 0
+	for i := range l.players {
+		if !l.players[i].vacant() {
+			n++
+		}
 	}
-	l := lobbies.ls[lobby]
-	l.Lock()
-	defer l.Unlock()
+	return n
+}
 
-	if n := len(l.players); n == 10 {
-		return 0, errors.New("max lobby size reached")
+// vacantSeat returns the first vacant seat or -1.
+func (l *Lobby) vacantSeat() int {
+	for i := range l.players {
+		if l.players[i].vacant() {
+			return i
+		}
 	}
-	for _, player := range l.players {
-		if player.Name == name {
-			return 0, fmt.Errorf("player with name %s already joined", player.Name)
+	return -1
+}
+
+// Leave gives up the seat of player.
+// While no game is running the seat is removed, see removePlayer.
+// During the game it is left vacant and the game pauses until it is taken.
+// If player was host the next Player becomes host.
+// The Lobby is closed once the last Player left.
+func Leave(lobby, player uint, token string) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorize(player, token)
+		if err != nil {
+			return err
+		}
+		if !l.running() {
+			l.removePlayer(game.Player(player), ReasonLeft)
+		} else {
+			l.vacate(game.Player(player))
+		}
+		if l.seated() == 0 {
+			closeLobby(l.Uuid, ReasonAbandoned)
+			return nil
+		}
+		if l.players[l.host].vacant() {
+			l.passHost()
 		}
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// removePlayer takes the seat of player away and moves everyone behind one seat up,
+// so positions stay equal to the index in players.
+// The removed player is told why, then their channel is closed.
+func (l *Lobby) removePlayer(player game.Player, reason CloseReason) {
+	l.disconnect(player, reason)
+	l.players = append(l.players[:player], l.players[player+1:]...)
+	for i := range l.players {
+		l.players[i].position = game.Player(i)
 	}
-	token, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
-	if err != nil {
-		return 0, fmt.Errorf("generating token: %v", err)
+	if l.host > player {
+		l.host--
+	} else if l.host == player && len(l.players) > 0 {
+		l.host = l.host % game.Player(len(l.players))
 	}
-	player := l.NewPlayer(name, token.String(), nil)
-	l.players = append(l.players, player)
+	l.seatsChanged()
+}
 
-	// TODO update player views?
-	return uint(player.position), nil
+// running tells if a game was started and didn't end yet.
+func (l *Lobby) running() bool {
+	if l.game == nil {
+		return false
+	}
+	_, ended := l.game.Result()
+	return !ended
 }
 
-func Claim(lobby, player uint, claim game.Cards) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
+// seatsChanged drops a finished game, it was dealt for other seats.
+// The Lobby is back to waiting for the next game then.
+func (l *Lobby) seatsChanged() {
+	if l.game != nil && !l.running() {
+		l.game = nil
+		l.finishedAt = time.Time{}
+	}
+}
 
-	err := l.game.Claim(game.Player(player), claim)
-	if err != nil {
-		l.broadcast(&ClaimMessage{err: err})
-	} else {
-		l.broadcast(&ClaimMessage{Cards: *l.game.Claims[player]})
+// vacate leaves the seat of player in the game empty, so another Player can take it.
+func (l *Lobby) vacate(player game.Player) {
+	l.disconnect(player, ReasonLeft)
+	p := &l.players[player]
+	p.token = ""
+	p.presence = PresenceAway
+}
+
+// disconnect tells player why they are no longer in the Lobby, then closes their channel.
+func (l *Lobby) disconnect(player game.Player, reason CloseReason) {
+	p := &l.players[player]
+	if p.sub != nil {
+		p.sub.send(&ClosingMessage{Reason: reason})
+		p.sub.finish()
+		p.sub = nil
 	}
 }
 
-func Play(lobby, from, to uint) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	err := l.game.Play(game.Player(from), game.Player(to))
-	if err != nil {
-		l.broadcast(&RevealCardMessage{err: err})
-	} else {
-		l.broadcast(&RevealCardMessage{Cards: l.game.RevealedCards})
+// passHost makes the next seated Player host.
+func (l *Lobby) passHost() {
+	for i := 1; i < len(l.players); i++ {
+		next := (int(l.host) + i) % len(l.players)
+		if !l.players[next].vacant() {
+			l.host = game.Player(next)
+			return
+		}
 	}
 }
 
-func GetRole(lobby, from uint) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.RLock()
-	defer l.RUnlock()
-	player := l.players[from]
-	if len(l.game.Roles) < int(from) {
-		err := fmt.Errorf("player position is not seated %d", player.position)
-		*player.channel <- &RoleMessage{err: err}
-	} else {
-		*player.channel <- &RoleMessage{Role: l.game.Roles[player.position]}
-	}
-}
-func GetHand(lobby, from uint) error {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.RLock()
-	defer l.RUnlock()
-	player := l.players[from]
+// PlayAsBot marks the seat owning token as played by a bot.
+// Bots don't survive restarts, so restored lobbies give up their seats, see restore.
+func PlayAsBot(lobby uint, token string) error {
+	return do(lobby, func(l *Lobby) error {
+		p := l.owner(token)
+		if p == nil {
+			return fmt.Errorf("%w: unknown token", ErrUnauthorized)
+		}
+		p.bot = true
+		l.dirty = true
+		return nil
+	})
+}
 
-	if len(l.game.Hands) < int(player.position) {
-		return fmt.Errorf("player position is not seated %d", player.position)
+// Authenticate returns the position of the Player owning token.
+func Authenticate(lobby uint, token string) (uint, error) {
+	var position uint
+	err := do(lobby, func(l *Lobby) error {
+		p := l.owner(token)
+		if p == nil {
+			return fmt.Errorf("%w: unknown token", ErrUnauthorized)
+		}
+		position = uint(p.position)
+		l.touch(p.position)
+		return nil
+	})
+	return position, err
+}
+
+// owner returns the Player owning token or nil.
+func (l *Lobby) owner(token string) *Player {
+	for i := range l.players {
+		if l.players[i].owns(token) {
+			return &l.players[i]
+		}
 	}
-	*player.channel <- &HandMessage{Cards: l.game.Hands[player.position]}
 	return nil
 }
 
-func GetGameState(lobby uint) {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	l := lobbies.ls[lobby]
-	l.broadcast(&StateMessage{State: l.game.State()})
+// Claim publishes the claim of player.
+func Claim(lobby, player uint, token string, claim game.Cards) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeGame(player, token)
+		if err != nil {
+			return err
+		}
+		err = l.game.Claim(game.Player(player), claim)
+		if err != nil {
+			return actionError{err}
+		}
+		l.acted()
+		l.broadcastPublic(claimMessage(game.Player(player), l.variant()))
+		// claims may be changed, the views render each claim once
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// Play lets from reveal a card of to.
+func Play(lobby, from uint, token string, to uint) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeGame(from, token)
+		if err != nil {
+			return err
+		}
+		err = l.game.Play(game.Player(from), game.Player(to))
+		if err != nil {
+			return actionError{err}
+		}
+		l.acted()
+		l.broadcastPublic(revealMessage(l.variant()))
+		l.broadcastPrivate(handMessage(l.variant()))
+		l.broadcastPublic(stateMessage)
+		// a new round resets all claims
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// GetRole returns the Role of from.
+// If from has a channel attached the Role is sent there, too.
+func GetRole(lobby, from uint, token string) (game.Role, error) {
+	var role game.Role
+	err := do(lobby, func(l *Lobby) error {
+		err := l.authorizeGame(from, token)
+		if err != nil {
+			return err
+		}
+		v := l.game.ViewOf(game.Player(from))
+		role = v.Role
+		l.sendTo(v.Seat, roleMessage(l.variant())(v))
+		return nil
+	})
+	return role, err
+}
+
+// GetHand returns the Hand of from.
+// If from has a channel attached the Hand is sent there, too.
+func GetHand(lobby, from uint, token string) (game.Cards, error) {
+	var hand game.Cards
+	err := do(lobby, func(l *Lobby) error {
+		err := l.authorizeGame(from, token)
+		if err != nil {
+			return err
+		}
+		v := l.game.ViewOf(game.Player(from))
+		hand = v.Hand
+		l.sendTo(v.Seat, handMessage(l.variant())(v))
+		return nil
+	})
+	return hand, err
 }
 
-func (l *Lobby) broadcast(message Message) {
-	for _, p := range l.players {
-		if p.channel == nil {
-			message.SetError(fmt.Errorf("player %d has no channel attached", p.position))
+// GetEvents returns everything that happened in the game.
+// Events reveal all hands and roles, so they are only shown once the game ended.
+func GetEvents(lobby, from uint, token string) ([]game.Event, error) {
+	var events []game.Event
+	err := do(lobby, func(l *Lobby) error {
+		err := l.authorize(from, token)
+		if err != nil {
+			return err
 		}
-		*p.channel <- message
+		if l.game == nil {
+			return ErrNotStarted
+		}
+		if _, ended := l.game.Result(); !ended {
+			return fmt.Errorf("%w: events are hidden until the game ended", ErrInvalidAction)
+		}
+		events = l.game.Events()
+		return nil
+	})
+	return events, err
+}
+
+// authorize checks that token belongs to the Player seated at player.
+// Every authorized request counts as seeing player.
+func (l *Lobby) authorize(player uint, token string) error {
+	if int(player) >= len(l.players) || !l.players[player].owns(token) {
+		return fmt.Errorf("%w: seat %d", ErrUnauthorized, player)
 	}
+	l.touch(game.Player(player))
+	return nil
 }
 
-func Start(lobby uint) error {
-	lobbies.Lock()
-	defer lobbies.Unlock()
-	l := lobbies.ls[lobby]
-	l.Lock()
-	defer l.Unlock()
-	n := len(l.players)
-	if n < 3 || n > 10 {
-		return fmt.Errorf("can't start with %d players", n)
-	}
-	g, err := game.NewGame(n)
+// authorizeGame is authorize for actions that need a running game.
+func (l *Lobby) authorizeGame(player uint, token string) error {
+	err := l.authorize(player, token)
 	if err != nil {
 		return err
 	}
-	l.game = &g
+	if l.game == nil {
+		return ErrNotStarted
+	}
+	if l.vacantSeat() >= 0 {
+		return ErrPaused
+	}
 	return nil
 }
+
+// PublicState is everything about a Lobby all players may know.
+type PublicState struct {
+	Players  []string
+	Started  bool
+	State    game.State
+	Revealed game.Cards
+	Claims   []*game.Cards
+	// Result is nil until the game ended
+	Result *game.GameResult
+}
+
+func GetPublicState(lobby uint) (PublicState, error) {
+	var state PublicState
+	err := do(lobby, func(l *Lobby) error {
+		for _, p := range l.players {
+			state.Players = append(state.Players, p.Name)
+		}
+		if l.game == nil {
+			return nil
+		}
+		v := l.game.Public()
+		state.Started = true
+		state.State = v.State
+		state.Revealed = v.Revealed
+		state.Claims = v.Claims
+		state.Result = v.Result
+		return nil
+	})
+	return state, err
+}
+
+func GetGameState(lobby uint) error {
+	return do(lobby, func(l *Lobby) error {
+		if l.game == nil {
+			return ErrNotStarted
+		}
+		l.broadcastPublic(stateMessage)
+		return nil
+	})
+}
+
+// Start lets the host deal a new game.
+// Once a game is finished the host can start the next one.
+func Start(lobby, host uint, token string) error {
+	return do(lobby, func(l *Lobby) error {
+		err := l.authorizeHost(host, token)
+		if err != nil {
+			return err
+		}
+		if l.running() {
+			return ErrStarted
+		}
+		rules := l.rules()
+		n := len(l.players)
+		g, err := rules.NewGame(n, nil)
+		if err != nil {
+			// rulesets may skip player counts, so only NewGame knows
+			return fmt.Errorf("%w: can't start with %d players: %v", ErrInvalidAction, n, err)
+		}
+		g.LockClaims = l.options.LockClaims
+		l.game = &g
+		l.acted()
+		l.broadcastViews()
+		return nil
+	})
+}
+
+// Close removes the Lobby and stops its go routine.
 func Close(lobby uint) {
+	closeLobby(lobby, ReasonClosed)
+}
+
+// closeLobby removes the Lobby, its go routine tells all players why before it stops.
+func closeLobby(lobby uint, reason CloseReason) {
 	lobbies.Lock()
-	defer lobbies.Unlock()
+	l, ok := lobbies.ls[lobby]
+	delete(lobbies.ls, lobby)
+	lobbies.Unlock()
 
-	if _, ok := lobbies.ls[lobby]; ok {
-		delete(lobbies.ls, lobby)
+	if ok {
+		l.closing <- reason
 	}
 }
@@ -1,59 +1,513 @@
 package lobby
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
 
 func TestNewLobby(t *testing.T) {
-	lobby, err := CreateLobby("test")
+	lobby, _, err := CreateLobby("test")
 	defer Close(lobby)
 	if err != nil {
 		t.Fatalf("expected lobby to be created successfully %s", err)
 	}
-	l := getLobby(lobby)
-	if len(l.players) != 1 {
-		t.Fatalf("expected host to be joined")
-	}
+	inspect(lobby, func(l *Lobby) {
+		if len(l.players) != 1 {
+			t.Errorf("expected host to be joined")
+		}
+	})
 
 }
 
+func TestJoinErrors(t *testing.T) {
+	started, _ := CreateTestLobby()
+	defer Close(started)
+	_, _, err := Join(started, "test5")
+	if !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected join of running game to be rejected, got: %v", err)
+	}
+	lobby, _, _ := CreateLobby("test")
+	defer Close(lobby)
+	_, _, err = Join(lobby, "test")
+	if !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected duplicate name to be rejected, got: %v", err)
+	}
+	for i := 2; i <= 10; i++ {
+		_, _, err = Join(lobby, fmt.Sprintf("test%d", i))
+		if err != nil {
+			t.Fatalf("expected player %d to join, got: %v", i, err)
+		}
+	}
+	_, _, err = Join(lobby, "test\r11")
+	if !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected name with control characters to be rejected, got: %v", err)
+	}
+	_, _, err = Join(lobby, "test11")
+	if !errors.Is(err, ErrLobbyFull) {
+		t.Fatalf("expected full lobby to be rejected, got: %v", err)
+	}
+	Close(lobby)
+	_, _, err = Join(lobby, "test12")
+	if !errors.Is(err, ErrLobbyNotFound) {
+		t.Fatalf("expected closed lobby to be not found, got: %v", err)
+	}
+}
+
 func TestChangePlayerName(t *testing.T) {
-	lobby := CreateTestLobby()
+	lobby, tokens := CreateTestLobby()
 	defer Close(lobby)
-	SetName(lobby, 1, "Changed")
-	l := getLobby(lobby)
-	if l.players[1].Name != "Changed" {
-		t.Fatalf("Could not change Players name")
+	err := SetName(lobby, 1, tokens[1], "Changed")
+	if err != nil {
+		t.Fatalf("expected name change to succeed, got: %v", err)
+	}
+	inspect(lobby, func(l *Lobby) {
+		if l.players[1].Name != "Changed" {
+			t.Errorf("Could not change Players name")
+		}
+	})
+	if err := SetName(lobby, 2, tokens[2], "Changed"); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected duplicate name to be rejected, got: %v", err)
+	}
+	if err := SetName(lobby, 2, tokens[2], " Changed "); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected duplicate name with spaces to be rejected, got: %v", err)
 	}
+	for _, name := range []string{"", "   ", strings.Repeat("x", maxNameLength+1), "a\rb", "a\nb"} {
+		if err := SetName(lobby, 2, tokens[2], name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("expected name %q to be rejected, got: %v", name, err)
+		}
+	}
+	if err := SetName(lobby, 2, tokens[2], "  Trimmed\t"); err != nil {
+		t.Fatalf("expected name change to succeed, got: %v", err)
+	}
+	inspect(lobby, func(l *Lobby) {
+		if l.players[2].Name != "Trimmed" {
+			t.Errorf("expected name to be trimmed, got: %q", l.players[2].Name)
+		}
+	})
 }
 
 func TestNewPlayer(t *testing.T) {
-	lobby, _ := CreateLobby("test")
-	l := getLobby(lobby)
-	l.RLock()
-	defer l.RUnlock()
-	if l.players[0].position != 0 {
-		t.Fatalf("expected first player to have position 0 but it has %d", l.players[0].position)
-	}
+	lobby, _, _ := CreateLobby("test")
+	defer Close(lobby)
+	inspect(lobby, func(l *Lobby) {
+		if l.players[0].position != 0 {
+			t.Errorf("expected first player to have position 0 but it has %d", l.players[0].position)
+		}
+	})
 }
 
 func TestStartLobby(t *testing.T) {
-	lobby := CreateTestLobby()
+	lobby, _ := CreateTestLobby()
+	defer Close(lobby)
+	inspect(lobby, func(l *Lobby) {
+		if len(l.players) != 4 {
+			t.Errorf("expected four players but it has %d", len(l.players))
+		}
+		if len(l.players) != len(l.game.Roles) {
+			t.Errorf("expected player count to match role count")
+		}
+	})
+}
+
+func TestStartWithoutSetup(t *testing.T) {
+	gapped := *game.DefaultRuleset
+	gapped.Name = "Gapped"
+	gapped.Setups = nil
+	for _, s := range game.DefaultRuleset.Setups {
+		if s.Players != 5 {
+			gapped.Setups = append(gapped.Setups, s)
+		}
+	}
+	if err := AddRuleset(&gapped); err != nil {
+		t.Fatal(err)
+	}
+	lobby, host, _ := CreateLobbyWith("host", Options{Ruleset: gapped.Name})
 	defer Close(lobby)
-	l := getLobby(lobby)
-	l.RLock()
-	defer l.RUnlock()
-	if len(l.players) != 4 {
-		t.Fatalf("expected four players but it has %d", len(l.players))
+	for _, name := range []string{"a", "b", "c", "d"} {
+		Join(lobby, name)
+	}
+	if err := Start(lobby, 0, host); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected no game without a setup for 5 players, got: %v", err)
+	}
+}
+
+func TestHost(t *testing.T) {
+	lobby, host, _ := CreateLobby("host")
+	defer Close(lobby)
+	tokens := []string{host}
+	for _, name := range []string{"a", "b", "c"} {
+		_, token, _ := Join(lobby, name)
+		tokens = append(tokens, token)
+	}
+	if err := Start(lobby, 1, tokens[1]); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected only host to start, got: %v", err)
+	}
+	if err := SetLocked(lobby, 0, host, true); err != nil {
+		t.Fatalf("expected host to lock lobby, got: %v", err)
+	}
+	if _, _, err := Join(lobby, "d"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected locked lobby to reject join, got: %v", err)
+	}
+
+	channel := make(chan Message)
+	Register(lobby, tokens[1], &channel)
+	<-channel
+	if err := Kick(lobby, 0, host, 1); err != nil {
+		t.Fatalf("expected host to kick, got: %v", err)
+	}
+	if m, ok := (<-channel).(*ClosingMessage); !ok || m.Reason != ReasonKicked {
+		t.Fatalf("expected kicked player to be told, got: %+v", m)
+	}
+	if _, ok := <-channel; ok {
+		t.Fatalf("expected channel of kicked player to be closed")
+	}
+	if _, err := Authenticate(lobby, tokens[1]); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected kicked player to lose their seat, got: %v", err)
+	}
+	seat, _ := Authenticate(lobby, tokens[3])
+	if seat != 2 {
+		t.Fatalf("expected last player to move up to seat 2, got: %d", seat)
+	}
+
+	if err := TransferHost(lobby, 0, host, 2); err != nil {
+		t.Fatalf("expected host to hand over, got: %v", err)
+	}
+	if err := Start(lobby, 0, host); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected former host to not start, got: %v", err)
+	}
+	if err := Start(lobby, 2, tokens[3]); err != nil {
+		t.Fatalf("expected new host to start, got: %v", err)
+	}
+	if err := Start(lobby, 2, tokens[3]); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected running game to not restart, got: %v", err)
+	}
+	if err := Kick(lobby, 2, tokens[3], 0); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected no kicks in running game, got: %v", err)
+	}
+}
+
+func TestLeave(t *testing.T) {
+	lobby, host, _ := CreateLobby("host")
+	tokens := []string{host}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, token, _ := Join(lobby, name)
+		tokens = append(tokens, token)
+	}
+	if err := Leave(lobby, 0, host); err != nil {
+		t.Fatalf("expected host to leave, got: %v", err)
+	}
+	tokens = tokens[1:]
+	inspect(lobby, func(l *Lobby) {
+		for i, p := range l.players {
+			if p.position != game.Player(i) || !p.owns(tokens[i]) {
+				t.Errorf("expected players to move up a seat, got %s at %d", p.Name, p.position)
+			}
+		}
+		if l.host != 0 {
+			t.Errorf("expected next player to become host, got: %d", l.host)
+		}
+	})
+	if err := Start(lobby, 0, tokens[0]); err != nil {
+		t.Fatalf("expected game to start, got: %v", err)
+	}
+
+	if err := Leave(lobby, 1, tokens[1]); err != nil {
+		t.Fatalf("expected player to leave running game, got: %v", err)
+	}
+	if err := Claim(lobby, 0, tokens[0], game.Cards{}); !errors.Is(err, ErrPaused) {
+		t.Fatalf("expected game to pause, got: %v", err)
+	}
+	if err := TransferHost(lobby, 0, tokens[0], 1); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected vacant seat to not become host, got: %v", err)
+	}
+	seat, token, err := Join(lobby, "e")
+	if err != nil || seat != 1 {
+		t.Fatalf("expected new player to take vacant seat 1, got: %d %v", seat, err)
+	}
+	if _, _, err := Join(lobby, "f"); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected no more seats, got: %v", err)
+	}
+	if _, err := GetHand(lobby, 1, token); err != nil {
+		t.Fatalf("expected new player to play the seat, got: %v", err)
+	}
+
+	tokens[1] = token
+	for i, token := range tokens {
+		Leave(lobby, uint(i), token)
+	}
+	if _, err := GetPublicState(lobby); !errors.Is(err, ErrLobbyNotFound) {
+		t.Fatalf("expected lobby to close once everyone left, got: %v", err)
+	}
+}
+
+func TestBetweenGames(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	playToEnd(t, lobby, tokens)
+	if err := Leave(lobby, 3, tokens[3]); err != nil {
+		t.Fatalf("expected player to leave finished game, got: %v", err)
+	}
+	inspect(lobby, func(l *Lobby) {
+		if len(l.players) != 3 || l.game != nil {
+			t.Errorf("expected seat and finished game to be removed, got %d seats", len(l.players))
+		}
+	})
+	seat, token, err := Join(lobby, "new")
+	if err != nil || seat != 3 {
+		t.Fatalf("expected new player to join between games, got: %d %v", seat, err)
+	}
+	tokens[3] = token
+	if err := Kick(lobby, 0, tokens[0], 2); err != nil {
+		t.Fatalf("expected host to kick between games, got: %v", err)
+	}
+	tokens = append(tokens[:2], tokens[3])
+	if _, _, err := Join(lobby, "again"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(lobby, 0, tokens[0]); err != nil {
+		t.Fatalf("expected next game to start, got: %v", err)
+	}
+	if err := Kick(lobby, 0, tokens[0], 1); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected no kicks while running, got: %v", err)
+	}
+	if _, _, err := Join(lobby, "late"); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected no joins while running, got: %v", err)
+	}
+}
+
+func TestRegisterSendsView(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channel := make(chan Message)
+	if err := Register(lobby, "wrong", &channel); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected unknown token to be rejected, got: %v", err)
+	}
+	Register(lobby, tokens[2], &channel)
+	message, ok := (<-channel).(*ViewMessage)
+	if !ok {
+		t.Fatalf("expected first message to be a view")
+	}
+	v := message.View
+	if v.Seat != 2 || v.Me().Name != "test3" || len(v.Players) != 4 {
+		t.Fatalf("expected view of player 2 with all players, got: %+v", v)
+	}
+	hand, _ := GetHand(lobby, 2, tokens[2])
+	if v.Game == nil || v.Game.Hand != hand {
+		t.Fatalf("expected view to contain own hand: %v, got: %+v", hand, v.Game)
+	}
+	if _, err := GetView(lobby, 2, tokens[1]); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected to not get view of other player, got: %v", err)
+	}
+	Claim(lobby, 2, tokens[2], hand)
+	for message := range channel {
+		if m, ok := message.(*ViewMessage); ok {
+			if m.View.Game.Claims[2] == nil {
+				t.Fatalf("expected view with the claim, got: %+v", m.View.Game)
+			}
+			break
+		}
+	}
+}
+
+func TestSpectators(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channel := make(chan Message, maxQueue)
+	if err := Spectate(lobby, &channel); err != nil {
+		t.Fatalf("expected anyone to spectate, got: %v", err)
+	}
+	message, ok := (<-channel).(*SpectatorViewMessage)
+	if !ok || message.View.Game == nil || len(message.View.Players) != 4 {
+		t.Fatalf("expected first message to be the spectator view, got: %+v", message)
+	}
+	hand, _ := GetHand(lobby, 0, tokens[0])
+	Claim(lobby, 0, tokens[0], hand)
+	message, ok = (<-channel).(*SpectatorViewMessage)
+	if !ok || message.View.Game.Claims[0] == nil {
+		t.Fatalf("expected spectators to see claims, got: %+v", message)
+	}
+
+	if err := SetOptions(lobby, 0, tokens[0], Options{SpectatorDelay: maxSpectatorDelay + 1}); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected too long delay to be rejected, got: %v", err)
+	}
+	SetOptions(lobby, 0, tokens[0], Options{SpectatorDelay: 1})
+	<-channel
+	hand, _ = GetHand(lobby, 1, tokens[1])
+	Claim(lobby, 1, tokens[1], hand)
+	start := time.Now()
+	message = (<-channel).(*SpectatorViewMessage)
+	if time.Since(start) < 900*time.Millisecond || message.View.Game.Claims[1] == nil {
+		t.Fatalf("expected claim to be shown after the delay, got it after %v", time.Since(start))
+	}
+	late := make(chan Message, maxQueue)
+	Spectate(lobby, &late)
+	message = (<-late).(*SpectatorViewMessage)
+	if message.View.Game.Claims[1] == nil {
+		t.Fatalf("expected new spectators to start delayed, got: %+v", message.View.Game)
+	}
+
+	Close(lobby)
+	if _, ok := (<-channel).(*ClosingMessage); !ok {
+		t.Fatal("expected spectators to be told the lobby closed")
+	}
+	if _, ok := <-channel; ok {
+		t.Fatal("expected spectator channel to be closed")
+	}
+}
+
+func TestChat(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channel := make(chan Message, maxQueue)
+	Register(lobby, tokens[1], &channel)
+	<-channel
+	if err := Chat(lobby, 0, tokens[0], "  <b>hi</b> "); err != nil {
+		t.Fatalf("expected chat to be sent, got: %v", err)
+	}
+	message, ok := (<-channel).(*ChatMessage)
+	if !ok || message.Text != "<b>hi</b>" || message.Name != "test" {
+		t.Fatalf("expected chat message as plain text, got: %+v", message)
+	}
+	for _, text := range []string{" ", strings.Repeat("ü", maxChatLength+1), "\xff", "hi\revent: ViewMessage\rdata: x", "a\x00b"} {
+		if err := Chat(lobby, 0, tokens[0], text); !errors.Is(err, ErrInvalidMessage) {
+			t.Fatalf("expected %q to be rejected, got: %v", text, err)
+		}
+	}
+	if err := Chat(lobby, 0, tokens[0], strings.Repeat("ü", maxChatLength)); err != nil {
+		t.Fatalf("expected longest message to be sent, got: %v", err)
+	}
+	for i := 2; i < chatBurst; i++ {
+		Chat(lobby, 0, tokens[0], "spam")
+	}
+	if err := Chat(lobby, 0, tokens[0], "spam"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limit, got: %v", err)
+	}
+
+	if err := Mute(lobby, 1, tokens[1], 2, true); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected only host to mute, got: %v", err)
+	}
+	if err := Mute(lobby, 0, tokens[0], 2, true); err != nil {
+		t.Fatalf("expected host to mute, got: %v", err)
+	}
+	if err := Chat(lobby, 2, tokens[2], "hello"); !errors.Is(err, ErrMuted) {
+		t.Fatalf("expected muted player to not chat, got: %v", err)
+	}
+	v, _ := GetView(lobby, 1, tokens[1])
+	if !v.Players[2].Muted || len(v.Chat) != chatBurst {
+		t.Fatalf("expected view to show mute and chat, got: %+v %d", v.Players[2], len(v.Chat))
+	}
+	Mute(lobby, 0, tokens[0], 2, false)
+	if err := Chat(lobby, 2, tokens[2], "hello"); err != nil {
+		t.Fatalf("expected unmuted player to chat, got: %v", err)
+	}
+
+	inspect(lobby, func(l *Lobby) {
+		for len(l.chat) < maxChatHistory {
+			l.chat = append(l.chat, ChatMessage{Text: "old"})
+		}
+	})
+	Chat(lobby, 3, tokens[3], "new")
+	chat, _ := GetChat(lobby, 3, tokens[3])
+	if len(chat) != maxChatHistory || chat[len(chat)-1].Text != "new" {
+		t.Fatalf("expected history to keep the last %d messages, got: %d", maxChatHistory, len(chat))
+	}
+}
+
+func TestPresence(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channels := make([]chan Message, 4)
+	setupChannels(lobby, tokens, channels)
+	spectator := make(chan Message, maxQueue)
+	Spectate(lobby, &spectator)
+	<-spectator
+	inspect(lobby, func(l *Lobby) {
+		l.players[3].lastSeen = time.Now().Add(-2 * awayAfter)
+		l.updatePresence(time.Now())
+	})
+	for i := range channels {
+		m, ok := (<-channels[i]).(*PresenceMessage)
+		if !ok || m.Player.Seat != 3 || m.Player.Presence != PresenceAway {
+			t.Fatalf("expected player 3 to be away, got: %+v", m)
+		}
+	}
+	v, _ := GetView(lobby, 0, tokens[0])
+	if v.Players[3].Presence != PresenceAway {
+		t.Fatalf("expected view to show player 3 away, got: %v", v.Players[3])
 	}
+	if m, ok := (<-spectator).(*SpectatorViewMessage); !ok || m.View.Players[3].Presence != PresenceAway {
+		t.Fatalf("expected spectators to see player 3 away, got: %+v", m)
+	}
+	if _, err := Authenticate(lobby, tokens[3]); err != nil {
+		t.Fatalf("expected authenticate to succeed, got: %v", err)
+	}
+	for i := range channels {
+		m, ok := (<-channels[i]).(*PresenceMessage)
+		if !ok || m.Player.Seat != 3 || m.Player.Presence != PresenceOnline {
+			t.Fatalf("expected player 3 to be back online, got: %+v", m)
+		}
+	}
+}
 
-	if len(l.players) != len(l.game.Roles) {
-		t.Fatalf("expected player count to match role count")
+func TestJanitor(t *testing.T) {
+	idle, tokens := CreateTestLobby()
+	defer Close(idle)
+	channel := make(chan Message)
+	Register(idle, tokens[0], &channel)
+	<-channel
+
+	// idle lobbies count, even if players are still around
+	sweep(time.Now().Add(time.Hour), JanitorConfig{IdleTTL: 2 * time.Hour})
+	if _, err := GetPublicState(idle); err != nil {
+		t.Fatalf("expected lobby to be kept, got: %v", err)
+	}
+	sweep(time.Now().Add(3*time.Hour), JanitorConfig{IdleTTL: 2 * time.Hour})
+	if _, err := GetPublicState(idle); !errors.Is(err, ErrLobbyNotFound) {
+		t.Fatalf("expected idle lobby to be closed, got: %v", err)
+	}
+	m, ok := (<-channel).(*ClosingMessage)
+	if !ok || m.Reason != ReasonIdle {
+		t.Fatalf("expected closing message for idle lobby, got: %+v", m)
+	}
+	if _, ok := <-channel; ok {
+		t.Fatalf("expected channel to be closed after closing message")
 	}
+
+	abandoned, _ := CreateTestLobby()
+	defer Close(abandoned)
+	sweep(time.Now().Add(awayAfter+2*time.Minute), JanitorConfig{AbandonedTTL: time.Minute})
+	if _, err := GetPublicState(abandoned); !errors.Is(err, ErrLobbyNotFound) {
+		t.Fatalf("expected abandoned lobby to be closed, got: %v", err)
+	}
+
+	finished, _ := CreateTestLobby()
+	defer Close(finished)
+	inspect(finished, func(l *Lobby) {
+		l.finishedAt = time.Now().Add(-time.Hour)
+	})
+	sweep(time.Now(), JanitorConfig{FinishedGrace: time.Minute})
+	if _, err := GetPublicState(finished); !errors.Is(err, ErrLobbyNotFound) {
+		t.Fatalf("expected finished lobby to be closed, got: %v", err)
+	}
+
+	// zero interval falls back to the default instead of panicking
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	StartJanitor(ctx, JanitorConfig{IdleTTL: time.Minute})
 }
 
 func TestGameState(t *testing.T) {
-	lobby := CreateTestLobby()
+	lobby, tokens := CreateTestLobby()
 	channels := make([]chan Message, 4)
-	setupChannels(lobby, channels)
+	setupChannels(lobby, tokens, channels)
 	go GetGameState(lobby)
 	for i, _ := range channels {
 		message := <-channels[i]
@@ -63,12 +517,124 @@ func TestGameState(t *testing.T) {
 	}
 }
 
+func TestEvents(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	if _, err := GetEvents(lobby, 0, tokens[0]); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected events to be hidden while playing, got: %v", err)
+	}
+	playToEnd(t, lobby, tokens)
+	events, err := GetEvents(lobby, 1, tokens[1])
+	if err != nil {
+		t.Fatalf("expected events once the game ended, got: %v", err)
+	}
+	if events[0].Kind != game.EventStart || events[len(events)-1].Result == nil {
+		t.Fatalf("expected events from start to end, got: %+v", events)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UseStore(store); err != nil {
+		t.Fatalf("expected empty store to load, got: %v", err)
+	}
+	defer UseStore(nil)
+	lobby, tokens := CreateTestLobby()
+	hand, _ := GetHand(lobby, 0, tokens[0])
+	if err := Claim(lobby, 0, tokens[0], hand); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := GetView(lobby, 0, tokens[0])
+	snapshots, err := store.Load()
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected one saved lobby, got: %d %v", len(snapshots), err)
+	}
+
+	// a restart loses everything in memory
+	Close(lobby)
+	restarted, _ := NewFileStore(t.TempDir())
+	restarted.Save(snapshots[0])
+	n, err := UseStore(restarted)
+	if err != nil || n != 1 {
+		t.Fatalf("expected one restored lobby, got: %d %v", n, err)
+	}
+	defer Close(lobby)
+	after, err := GetView(lobby, 0, tokens[0])
+	if err != nil {
+		t.Fatalf("expected tokens to survive the restart, got: %v", err)
+	}
+	if !reflect.DeepEqual(before.Game, after.Game) || before.Host != after.Host {
+		t.Fatalf("expected restored view\n%+v, got\n%+v", before.Game, after.Game)
+	}
+	for i, token := range tokens[1:] {
+		hand, _ := GetHand(lobby, uint(i+1), token)
+		if err := Claim(lobby, uint(i+1), token, hand); err != nil {
+			t.Fatalf("expected restored game to go on, got: %v", err)
+		}
+	}
+	v, _ := GetView(lobby, 0, tokens[0])
+	holder := v.Game.KeyHolder
+	if err := Play(lobby, uint(holder), tokens[holder], uint(v.Game.Targets[0])); err != nil {
+		t.Fatalf("expected restored game to go on, got: %v", err)
+	}
+	snapshots, _ = restarted.Load()
+	played := false
+	for _, e := range snapshots[0].Game.Events {
+		played = played || e.Kind == game.EventPlay
+	}
+	if !played {
+		t.Fatalf("expected play to be saved, got: %+v", snapshots[0].Game.Events)
+	}
+}
+
+func TestStoreBots(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+	UseStore(store)
+	defer UseStore(nil)
+	lobby, tokens := CreateTestLobby()
+	if err := PlayAsBot(lobby, tokens[3]); err != nil {
+		t.Fatal(err)
+	}
+	waiting, host, _ := CreateLobby("host")
+	_, token, _ := Join(waiting, "bot")
+	PlayAsBot(waiting, token)
+	snapshots, _ := store.Load()
+	Close(lobby)
+	Close(waiting)
+
+	restarted, _ := NewFileStore(t.TempDir())
+	for _, s := range snapshots {
+		restarted.Save(s)
+	}
+	if n, err := UseStore(restarted); err != nil || n != 2 {
+		t.Fatalf("expected two restored lobbies, got: %d %v", n, err)
+	}
+	defer Close(lobby)
+	defer Close(waiting)
+	hand, _ := GetHand(lobby, 0, tokens[0])
+	if err := Claim(lobby, 0, tokens[0], hand); !errors.Is(err, ErrPaused) {
+		t.Fatalf("expected seat of bot to be vacant, got: %v", err)
+	}
+	if seat, _, err := Join(lobby, "human"); err != nil || seat != 3 {
+		t.Fatalf("expected new player to take the seat of the bot, got: %d %v", seat, err)
+	}
+	if state, _ := GetPublicState(waiting); len(state.Players) != 1 {
+		t.Fatalf("expected bot to leave lobby without game, got: %v", state.Players)
+	}
+	if _, err := GetView(waiting, 0, host); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestGetHand(t *testing.T) {
-	lobby := CreateTestLobby()
+	lobby, tokens := CreateTestLobby()
 	channels := make([]chan Message, 4)
-	setupChannels(lobby, channels)
+	setupChannels(lobby, tokens, channels)
 	for i, _ := range channels {
-		go GetHand(lobby, uint(i))
+		go GetHand(lobby, uint(i), tokens[i])
 		message := <-channels[i]
 		if message.GetKind() != "HandMessage" {
 			t.Fatalf("expected Hand message to be broadcast")
@@ -76,24 +642,293 @@ func TestGetHand(t *testing.T) {
 	}
 }
 
-func setupChannels(lobby uint, channels []chan Message) {
+func TestUnauthorized(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channels := make([]chan Message, 4)
+	setupChannels(lobby, tokens, channels)
+	if _, err := GetHand(lobby, 1, tokens[0]); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected to not get hand of other player, got: %v", err)
+	}
+	if _, err := GetRole(lobby, 1, ""); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected to not get role without token, got: %v", err)
+	}
+	if err := Claim(lobby, 2, tokens[3], game.Cards{}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected to not claim for other player, got: %v", err)
+	}
+	if err := Play(lobby, 0, tokens[1], 1); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected to not play for other player, got: %v", err)
+	}
+	seat, err := Authenticate(lobby, tokens[2])
+	if err != nil || seat != 2 {
+		t.Fatalf("expected token to authenticate seat 2, got: %d %v", seat, err)
+	}
+}
+
+func TestInvalidAction(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	if err := Play(lobby, 0, tokens[0], 1); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected play while claiming to be rejected, got: %v", err)
+	}
+	if err := Claim(lobby, 1, tokens[1], game.Cards{Neutral: 5}); err != nil {
+		t.Fatalf("expected claim to succeed, got: %v", err)
+	}
+	state, err := GetPublicState(lobby)
+	if err != nil {
+		t.Fatalf("expected public state, got: %v", err)
+	}
+	if len(state.Players) != 4 || !state.Started || state.State != game.StateClaiming {
+		t.Fatalf("unexpected public state: %+v", state)
+	}
+	if state.Claims[0] != nil || *state.Claims[1] != (game.Cards{Neutral: 5}) {
+		t.Fatalf("expected only claim of player 1, got: %v", state.Claims)
+	}
+	var gameErr *game.Error
+	if err := Claim(lobby, 2, tokens[2], game.Cards{Neutral: 1}); !errors.As(err, &gameErr) || gameErr.Code != game.ErrClaimCount {
+		t.Fatalf("expected game error code to be relayed, got: %v", err)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	if _, _, err := CreateLobbyWith("host", Options{Variant: "Unknown"}); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected unknown variant to be rejected, got: %v", err)
+	}
+	if _, _, err := CreateLobbyWith("host", Options{Variant: game.Timebomb.Name, Ruleset: game.CthulhuRuleset.Name}); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected variant without special cards to be rejected for rules dealing them, got: %v", err)
+	}
+	lobby, host, err := CreateLobbyWith("host", Options{Variant: game.Cthulhu.Name})
+	if err != nil {
+		t.Fatalf("expected lobby to be created, got: %v", err)
+	}
+	defer Close(lobby)
+	tokens := []string{host}
+	for _, name := range []string{"a", "b"} {
+		_, token, _ := Join(lobby, name)
+		tokens = append(tokens, token)
+	}
+	Start(lobby, 0, host)
+	v, _ := GetView(lobby, 0, host)
+	if v.Variant != game.Cthulhu || v.Game.Hand.Special+v.Game.Hand.Neutral+v.Game.Hand.Good+v.Game.Hand.Bad != 5 {
+		t.Fatalf("expected cthulhu game, got: %+v", v)
+	}
+	inspect(lobby, func(l *Lobby) {
+		if l.rules() != game.CthulhuRuleset {
+			t.Errorf("expected rules of the variant, got: %s", l.rules().Name)
+		}
+	})
+	if err := SetOptions(lobby, 0, host, Options{Variant: game.Timebomb.Name}); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected variant change of a running game to be rejected, got: %v", err)
+	}
+	if err := SetOptions(lobby, 0, host, Options{Variant: game.Cthulhu.Name, LockClaims: true}); err != nil {
+		t.Fatalf("expected other options to change while running, got: %v", err)
+	}
+	playToEnd(t, lobby, tokens)
+	if err := SetOptions(lobby, 0, host, Options{Variant: game.Timebomb.Name}); err != nil {
+		t.Fatalf("expected variant change after the game, got: %v", err)
+	}
+	v, _ = GetView(lobby, 0, host)
+	if v.Variant != game.Timebomb || v.Game != nil {
+		t.Fatalf("expected finished game to be dropped with the variant, got: %+v", v)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	lobby, host, _ := CreateLobby("host")
+	defer Close(lobby)
+	tokens := []string{host}
+	for _, name := range []string{"a", "b"} {
+		_, token, _ := Join(lobby, name)
+		tokens = append(tokens, token)
+	}
+	if err := SetOptions(lobby, 1, tokens[1], Options{LockClaims: true}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected only host to set options, got: %v", err)
+	}
+	if err := SetOptions(lobby, 0, host, Options{Ruleset: "Unknown"}); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected unknown ruleset to be rejected, got: %v", err)
+	}
+	if err := SetOptions(lobby, 0, host, Options{LockClaims: true}); err != nil {
+		t.Fatalf("expected host to set options, got: %v", err)
+	}
+	Start(lobby, 0, host)
+	hand, _ := GetHand(lobby, 1, tokens[1])
+	if err := Claim(lobby, 1, tokens[1], hand); err != nil {
+		t.Fatalf("expected claim to succeed, got: %v", err)
+	}
+	if err := Claim(lobby, 1, tokens[1], hand); !errors.Is(err, game.ErrClaimLocked) || !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected claims to be locked, got: %v", err)
+	}
+}
+
+func TestConcurrentActions(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Add(1)
+		go func(i uint, token string) {
+			defer wg.Done()
+			hand, err := GetHand(lobby, i, token)
+			if err != nil {
+				t.Errorf("expected hand, got: %v", err)
+				return
+			}
+			if err := Claim(lobby, i, token, hand); err != nil {
+				t.Errorf("expected claim to succeed, got: %v", err)
+			}
+			if err := SetName(lobby, i, token, fmt.Sprintf("renamed%d", i)); err != nil {
+				t.Errorf("expected rename to succeed, got: %v", err)
+			}
+		}(uint(i), token)
+	}
+	wg.Wait()
+	state, _ := GetPublicState(lobby)
+	if state.State != game.StatePlaying {
+		t.Fatalf("expected all claims to be registered, got state: %v", state.State)
+	}
+}
+
+func TestSlowSubscriberDetached(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channels := make([]chan Message, 4)
+	setupChannels(lobby, tokens, channels)
+	// player 0 never reads
+	for n := 0; n < 2*maxQueue; n++ {
+		inspect(lobby, func(l *Lobby) {
+			l.broadcast(&ClaimMessage{})
+		})
+		for i := 1; i < 4; i++ {
+			if _, ok := <-channels[i]; !ok {
+				t.Fatalf("expected player %d to stay attached", i)
+			}
+		}
+	}
+	inspect(lobby, func(l *Lobby) {
+		if l.players[0].sub != nil {
+			t.Errorf("expected lagging subscriber to be detached")
+		}
+	})
+	for range channels[0] {
+		// closed after detach
+	}
+}
+
+func TestSnapshotsCoalesce(t *testing.T) {
+	s := newSubscriber(make(chan Message))
+	defer s.detach()
+	s.mu.Lock()
+	// keep pump from taking messages out of the queue
+	for i := 0; i < 3; i++ {
+		s.queue = append(s.queue, &ClaimMessage{})
+	}
+	s.mu.Unlock()
+	for i := 0; i < 2*maxQueue; i++ {
+		if !s.send(&StateMessage{State: game.State(i % 4)}) {
+			t.Fatalf("expected snapshots to never fill the queue")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) > 4 {
+		t.Fatalf("expected state snapshots to be coalesced, got queue: %d", len(s.queue))
+	}
+}
+
+func TestPrivateMessages(t *testing.T) {
+	lobby, tokens := CreateTestLobby()
+	defer Close(lobby)
+	channels := make([]chan Message, 4)
+	setupChannels(lobby, tokens, channels)
+	received := make([][]Message, 4)
+	var wg sync.WaitGroup
+	for i := range channels {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for m := range channels[i] {
+				received[i] = append(received[i], m)
+			}
+		}(i)
+	}
+	for i, token := range tokens {
+		hand, _ := GetHand(lobby, uint(i), token)
+		Claim(lobby, uint(i), token, hand)
+	}
+	if err := Play(lobby, 0, tokens[0], 2); err != nil {
+		t.Fatalf("expected play to succeed, got: %v", err)
+	}
+	var hands []game.Cards
+	inspect(lobby, func(l *Lobby) {
+		hands = l.game.Hands
+	})
+	Close(lobby)
+	wg.Wait()
+	for i := range received {
+		var hand *HandMessage
+		for _, m := range received[i] {
+			switch m := m.(type) {
+			case *HandMessage:
+				hand = m
+			case *RoleMessage:
+				t.Fatalf("expected no role to be sent to player %d", i)
+			}
+		}
+		if hand == nil || hand.Cards != hands[i] {
+			t.Fatalf("expected player %d to receive own hand %v, got: %v", i, hands[i], hand)
+		}
+		if hand.Variant != game.Timebomb {
+			t.Fatalf("expected hand to name its cards by variant, got: %v", hand.Variant)
+		}
+	}
+}
+
+// setupChannels registers channels and consumes the initial ViewMessage.
+func setupChannels(lobby uint, tokens []string, channels []chan Message) {
 	for i, _ := range channels {
 		channels[i] = make(chan Message)
-		Register(lobby, uint(i), &channels[i])
+		Register(lobby, tokens[i], &channels[i])
+		<-channels[i]
+	}
+}
+
+// playToEnd claims honestly and plays the first target until the game ended.
+func playToEnd(t *testing.T, lobby uint, tokens []string) {
+	for {
+		v, _ := GetView(lobby, 0, tokens[0])
+		if v.Game.Result != nil {
+			break
+		}
+		if v.Game.State == game.StateClaiming {
+			for i, token := range tokens {
+				hand, _ := GetHand(lobby, uint(i), token)
+				Claim(lobby, uint(i), token, hand)
+			}
+			continue
+		}
+		holder := v.Game.KeyHolder
+		err := Play(lobby, uint(holder), tokens[holder], uint(v.Game.Targets[0]))
+		if err != nil {
+			t.Fatalf("expected play to succeed, got: %v", err)
+		}
 	}
 }
 
-func getLobby(lobby uint) *Lobby {
-	lobbies.RLock()
-	defer lobbies.RUnlock()
-	return lobbies.ls[lobby]
+// inspect runs fn in the go routine of lobby.
+func inspect(lobby uint, fn func(l *Lobby)) {
+	do(lobby, func(l *Lobby) error {
+		fn(l)
+		return nil
+	})
 }
 
-func CreateTestLobby() uint {
-	lobby, _ := CreateLobby("test")
-	Join(lobby, "test2")
-	Join(lobby, "test3")
-	Join(lobby, "test4")
-	Start(lobby)
-	return lobby
+func CreateTestLobby() (uint, []string) {
+	lobby, host, _ := CreateLobby("test")
+	tokens := []string{host}
+	for _, name := range []string{"test2", "test3", "test4"} {
+		_, token, _ := Join(lobby, name)
+		tokens = append(tokens, token)
+	}
+	Start(lobby, 0, host)
+	return lobby, tokens
 }
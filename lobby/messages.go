@@ -1,93 +1,123 @@
 package lobby
 
-import "github.com/c-goetz/traitor-card-game/game"
+import (
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
 
 type Message interface {
 	GetKind() string
-	GetError() error
-	SetError(err error)
 }
 
+// snapshot is implemented by Messages that carry the complete current value,
+// a newer snapshot makes older ones of the same kind obsolete.
+type snapshot interface {
+	Message
+	snapshot()
+}
+
+// Variant of the game names the Cards and Roles of messages.
 type ClaimMessage struct {
-	err   error
-	Cards game.Cards
+	Player  game.Player
+	Cards   game.Cards
+	Variant *game.Variant
 }
 type RevealCardMessage struct {
-	err   error
-	Cards game.Cards
+	Cards     game.Cards
+	KeyHolder game.Player
+	Variant   *game.Variant
 }
 
 type RoleMessage struct {
-	err  error
-	Role game.Role
+	Role    game.Role
+	Variant *game.Variant
 }
 
 type StateMessage struct {
-	err   error
 	State game.State
 }
 
 type HandMessage struct {
-	err   error
-	Cards game.Cards
+	Cards   game.Cards
+	Variant *game.Variant
 }
 
-func (m *HandMessage) GetKind() string {
-	return "HandMessage"
+type PresenceMessage struct {
+	Player PlayerInfo
 }
 
-func (m *StateMessage) GetKind() string {
-	return "StateMessage"
+func (m *PresenceMessage) GetKind() string {
+	return "PresenceMessage"
 }
 
-func (m *RoleMessage) GetKind() string {
-	return "RoleMessage"
+// ClosingMessage is the last Message of a Lobby.
+type ClosingMessage struct {
+	Reason CloseReason
 }
 
-func (m *RevealCardMessage) GetKind() string {
-	return "RevealCardMessage"
+func (m *ClosingMessage) GetKind() string {
+	return "ClosingMessage"
 }
 
-func (m *ClaimMessage) GetKind() string {
-	return "ClaimMessage"
+// ChatMessage is one message of a player in the chat.
+type ChatMessage struct {
+	Player game.Player `json:"player"`
+	// Name of the player when the message was sent
+	Name string    `json:"name"`
+	Text string    `json:"text"`
+	At   time.Time `json:"at"`
 }
 
-func (m *HandMessage) GetError() error {
-	return m.err
+func (m *ChatMessage) GetKind() string {
+	return "ChatMessage"
 }
 
-func (m *StateMessage) GetError() error {
-	return m.err
+type ViewMessage struct {
+	View PlayerView
 }
 
-func (m *RoleMessage) GetError() error {
-	return m.err
+func (m *ViewMessage) GetKind() string {
+	return "ViewMessage"
 }
 
-func (m *RevealCardMessage) GetError() error {
-	return m.err
+func (m *ViewMessage) snapshot() {}
+
+// SpectatorViewMessage is the only Message spectators get besides ClosingMessage.
+type SpectatorViewMessage struct {
+	View SpectatorView
 }
 
-func (m *ClaimMessage) GetError() error {
-	return m.err
+func (m *SpectatorViewMessage) GetKind() string {
+	return "SpectatorViewMessage"
 }
 
-func (m *HandMessage) SetError(err error) {
-	m.err = err
+func (m *SpectatorViewMessage) snapshot() {}
+
+func (m *HandMessage) GetKind() string {
+	return "HandMessage"
 }
 
-func (m *StateMessage) SetError(err error) {
-	m.err = err
+func (m *StateMessage) GetKind() string {
+	return "StateMessage"
 }
 
-func (m *RoleMessage) SetError(err error) {
-	m.err = err
+func (m *RoleMessage) GetKind() string {
+	return "RoleMessage"
 }
 
-func (m *RevealCardMessage) SetError(err error) {
-	m.err = err
+func (m *RevealCardMessage) GetKind() string {
+	return "RevealCardMessage"
 }
 
-func (m *ClaimMessage) SetError(err error) {
-	m.err = err
+func (m *ClaimMessage) GetKind() string {
+	return "ClaimMessage"
 }
+
+func (m *HandMessage) snapshot() {}
+
+func (m *StateMessage) snapshot() {}
+
+func (m *RoleMessage) snapshot() {}
+
+func (m *RevealCardMessage) snapshot() {}
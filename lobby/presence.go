@@ -0,0 +1,63 @@
+package lobby
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// awayAfter is how long a Player may not be seen before being marked away.
+// Every authorized call counts as seen, e.g. the heartbeat of the event stream calls Authenticate.
+const awayAfter = 30 * time.Second
+
+// presenceInterval is how often a Lobby checks for players that went away.
+const presenceInterval = 5 * time.Second
+
+type Presence uint8
+
+const (
+	PresenceOnline Presence = iota
+	// PresenceAway players keep their seat and can come back any time
+	PresenceAway
+)
+
+func (p Presence) String() string {
+	switch p {
+	case PresenceOnline:
+		return "Online"
+	case PresenceAway:
+		return "Away"
+	}
+	return fmt.Sprintf("Presence(%d)", p)
+}
+
+func (p Presence) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// touch updates lastSeen of player and tells everyone if player came back.
+func (l *Lobby) touch(player game.Player) {
+	p := &l.players[player]
+	p.lastSeen = time.Now()
+	l.setPresence(player, PresenceOnline)
+}
+
+// updatePresence marks all players not seen since awayAfter as away.
+func (l *Lobby) updatePresence(now time.Time) {
+	for _, p := range l.players {
+		if now.Sub(p.lastSeen) > awayAfter && !p.vacant() {
+			l.setPresence(p.position, PresenceAway)
+		}
+	}
+}
+
+func (l *Lobby) setPresence(player game.Player, presence Presence) {
+	p := &l.players[player]
+	if p.presence == presence {
+		return
+	}
+	p.presence = presence
+	l.broadcast(&PresenceMessage{Player: l.playerInfo(player)})
+	l.broadcastSpectators()
+}
@@ -0,0 +1,79 @@
+package lobby
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+// rulesets the host of a Lobby can choose from, by name.
+var rulesets = struct {
+	sync.RWMutex
+	rs map[string]*game.Ruleset
+}{
+	rs: map[string]*game.Ruleset{game.DefaultRuleset.Name: game.DefaultRuleset},
+}
+
+// AddRuleset offers rules to every Lobby, names must be unique.
+func AddRuleset(rules *game.Ruleset) error {
+	err := rules.Validate()
+	if err != nil {
+		return err
+	}
+	rulesets.Lock()
+	defer rulesets.Unlock()
+	if _, ok := rulesets.rs[rules.Name]; ok {
+		return fmt.Errorf("ruleset %s already exists", rules.Name)
+	}
+	rulesets.rs[rules.Name] = rules
+	return nil
+}
+
+// Rulesets returns the names of all rulesets, sorted.
+func Rulesets() []string {
+	rulesets.RLock()
+	defer rulesets.RUnlock()
+	names := make([]string, 0, len(rulesets.rs))
+	for name := range rulesets.rs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	// every Variant brings its own rules
+	for _, v := range game.Variants {
+		if _, ok := rulesets.rs[v.Rules.Name]; !ok {
+			rulesets.rs[v.Rules.Name] = v.Rules
+		}
+	}
+}
+
+// ruleset returns the Ruleset called name.
+func ruleset(name string) (*game.Ruleset, bool) {
+	rulesets.RLock()
+	defer rulesets.RUnlock()
+	rules, ok := rulesets.rs[name]
+	return rules, ok
+}
+
+// variant of the next game, Options only contain known variants.
+func (l *Lobby) variant() *game.Variant {
+	variant, ok := game.VariantByName(l.options.Variant)
+	if !ok {
+		return game.Variants[0]
+	}
+	return variant
+}
+
+// rules of the next game, the Ruleset of the options or else of the variant.
+func (l *Lobby) rules() *game.Ruleset {
+	rules, ok := ruleset(l.options.Ruleset)
+	if !ok {
+		return l.variant().Rules
+	}
+	return rules
+}
@@ -0,0 +1,191 @@
+package lobby
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+/*
+Spectators watch a Lobby without a seat, everyone with the link may watch.
+They only get SpectatorViews, built from the game.PublicView, so they never
+see hands or roles before the game ended. Options.SpectatorDelay holds every
+SpectatorView back for a while, so watching can't help the players.
+The last views of the delay are kept, new spectators start delayed, too.
+*/
+
+// maxSpectators per Lobby, they don't count towards the players.
+const maxSpectators = 64
+
+// maxSpectatorDelay in seconds.
+const maxSpectatorDelay = 600
+
+// SpectatorView is everything spectators may know about a Lobby.
+type SpectatorView struct {
+	Players []PlayerInfo  `json:"players"`
+	Host    game.Player   `json:"host"`
+	Locked  bool          `json:"locked"`
+	Variant *game.Variant `json:"variant"`
+	// Game is nil until the game was started
+	Game *game.PublicView `json:"game"`
+}
+
+type spectator struct {
+	sub *subscriber
+	mu  sync.Mutex
+	// sent is the seq of the newest view sent, older ones are dropped
+	sent uint64
+	// detached spectators lagged behind, the Lobby forgets them with the next view
+	detached bool
+}
+
+// spectated is a SpectatorView and when it was built.
+type spectated struct {
+	at   time.Time
+	seq  uint64
+	view SpectatorView
+}
+
+// deliver sends view unless a newer one was sent already.
+// It is called by timers, not by the go routine of the Lobby.
+func (s *spectator) deliver(seq uint64, view SpectatorView) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detached || seq <= s.sent {
+		return
+	}
+	s.sent = seq
+	if !s.sub.send(&SpectatorViewMessage{View: view}) {
+		s.sub.detach()
+		s.detached = true
+	}
+}
+
+// Spectate attaches channel to lobby, all SpectatorViews are sent there.
+// The first Message is always a SpectatorViewMessage.
+// Like Register the Lobby closes channel when it detaches it.
+func Spectate(lobby uint, channel *chan Message) error {
+	return do(lobby, func(l *Lobby) error {
+		if len(l.spectators) >= maxSpectators {
+			return fmt.Errorf("%w: %d spectators", ErrLobbyFull, len(l.spectators))
+		}
+		now := time.Now()
+		first, seq := l.delayedView(now)
+		s := &spectator{sub: newSubscriber(*channel), sent: seq}
+		s.sub.send(&SpectatorViewMessage{View: first})
+		l.spectators = append(l.spectators, s)
+		for _, v := range l.spectated {
+			if v.seq > seq {
+				l.schedule(s, v, now)
+			}
+		}
+		return nil
+	})
+}
+
+// StopSpectating detaches channel, it is closed then.
+func StopSpectating(lobby uint, channel *chan Message) {
+	do(lobby, func(l *Lobby) error {
+		for i, s := range l.spectators {
+			if s.sub.out == *channel {
+				s.sub.detach()
+				l.spectators = append(l.spectators[:i], l.spectators[i+1:]...)
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// GetSpectatorView returns the SpectatorView of lobby as old as the delay.
+func GetSpectatorView(lobby uint) (SpectatorView, error) {
+	var v SpectatorView
+	err := do(lobby, func(l *Lobby) error {
+		v, _ = l.delayedView(time.Now())
+		return nil
+	})
+	return v, err
+}
+
+// delay is how long SpectatorViews are held back.
+func (l *Lobby) delay() time.Duration {
+	return time.Duration(l.options.SpectatorDelay) * time.Second
+}
+
+// spectatorView is what everyone may see of the Lobby.
+func (l *Lobby) spectatorView() SpectatorView {
+	v := SpectatorView{Host: l.host, Locked: l.locked, Variant: l.variant()}
+	for _, p := range l.players {
+		v.Players = append(v.Players, l.playerInfo(p.position))
+	}
+	if l.game != nil {
+		gv := l.game.Public()
+		v.Game = &gv
+	}
+	return v
+}
+
+// delayedView returns the newest SpectatorView that is at least as old as the delay.
+// If there is none, e.g. because the delay was raised, the game is left out.
+func (l *Lobby) delayedView(now time.Time) (SpectatorView, uint64) {
+	if len(l.spectated) == 0 {
+		// nothing happened since the Lobby was restored
+		l.broadcastSpectators()
+	}
+	oldest := l.spectated[0]
+	if oldest.at.After(now.Add(-l.delay())) {
+		v := oldest.view
+		v.Game = nil
+		return v, 0
+	}
+	return oldest.view, oldest.seq
+}
+
+// broadcastSpectators sends every spectator the current SpectatorView once it is as old as the delay.
+func (l *Lobby) broadcastSpectators() {
+	now := time.Now()
+	l.spectatorSeq++
+	v := spectated{now, l.spectatorSeq, l.spectatorView()}
+	l.spectated = append(l.spectated, v)
+	// keep the newest view old enough for new spectators
+	cutoff := now.Add(-l.delay())
+	for len(l.spectated) > 1 && !l.spectated[1].at.After(cutoff) {
+		l.spectated = l.spectated[1:]
+	}
+	spectators := l.spectators[:0]
+	for _, s := range l.spectators {
+		s.mu.Lock()
+		detached := s.detached
+		s.mu.Unlock()
+		if detached {
+			continue
+		}
+		spectators = append(spectators, s)
+		l.schedule(s, v, now)
+	}
+	l.spectators = spectators
+}
+
+// schedule delivers v to s once it is as old as the delay.
+func (l *Lobby) schedule(s *spectator, v spectated, now time.Time) {
+	wait := v.at.Add(l.delay()).Sub(now)
+	if wait <= 0 {
+		s.deliver(v.seq, v.view)
+		return
+	}
+	time.AfterFunc(wait, func() { s.deliver(v.seq, v.view) })
+}
+
+// closeSpectators tells all spectators the Lobby closed, then closes their channels.
+func (l *Lobby) closeSpectators(reason CloseReason) {
+	for _, s := range l.spectators {
+		s.mu.Lock()
+		s.detached = true
+		s.sub.send(&ClosingMessage{Reason: reason})
+		s.sub.finish()
+		s.mu.Unlock()
+	}
+	l.spectators = nil
+}
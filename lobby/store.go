@@ -0,0 +1,289 @@
+package lobby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/game"
+)
+
+/*
+A Store keeps a Snapshot of every Lobby, so lobbies survive restarts.
+Each Lobby saves itself after every player action, see persist, and is deleted
+from the Store when it is closed. Games are saved as their events and rebuilt
+with game.Replay. Tokens are part of the Snapshot, so sessions stay valid and
+clients only have to reconnect their channels, e.g. browsers reopen the event stream.
+Bots only live in memory, their seats are given up on restore like players leaving,
+so a running game pauses until new players took them.
+*/
+
+type Store interface {
+	// Save replaces the Snapshot of the Lobby snapshot.ID
+	Save(snapshot Snapshot) error
+	Delete(lobby uint) error
+	// Load returns all saved snapshots
+	Load() ([]Snapshot, error)
+}
+
+// Snapshot is everything a Store keeps of a Lobby.
+// Presence is not kept, restored players are away until they are seen again.
+type Snapshot struct {
+	ID      uint             `json:"id"`
+	Players []PlayerSnapshot `json:"players"`
+	Host    game.Player      `json:"host"`
+	Locked  bool             `json:"locked"`
+	Options Options          `json:"options"`
+	Chat    []ChatMessage    `json:"chat,omitempty"`
+	// Game is nil until the first game started
+	Game       *GameSnapshot `json:"game,omitempty"`
+	LastAction time.Time     `json:"lastAction"`
+	FinishedAt time.Time     `json:"finishedAt"`
+}
+
+type PlayerSnapshot struct {
+	Name string `json:"name"`
+	// Token is empty for vacant seats
+	Token string `json:"token"`
+	Muted bool   `json:"muted,omitempty"`
+	// Bot seats are given up on restore, see PlayAsBot
+	Bot bool `json:"bot,omitempty"`
+}
+
+type GameSnapshot struct {
+	// Ruleset is the name of the rules the game is played by, see AddRuleset
+	Ruleset    string       `json:"ruleset"`
+	LockClaims bool         `json:"lockClaims"`
+	Events     []game.Event `json:"events"`
+}
+
+// UseStore saves all lobbies in s from now on and restores the lobbies saved there.
+// Restored lobbies use the rulesets added until now.
+// A nil Store keeps lobbies in memory only.
+func UseStore(s Store) (int, error) {
+	if s == nil {
+		lobbies.Lock()
+		lobbies.store = nil
+		lobbies.Unlock()
+		return 0, nil
+	}
+	snapshots, err := s.Load()
+	if err != nil {
+		return 0, fmt.Errorf("loading lobbies: %w", err)
+	}
+	restored := make([]*Lobby, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		l, err := restore(snapshot)
+		if err != nil {
+			return 0, fmt.Errorf("restoring lobby %d: %w", snapshot.ID, err)
+		}
+		if l.seated() == 0 {
+			// only bots were left
+			err = s.Delete(l.Uuid)
+			if err != nil {
+				return 0, fmt.Errorf("deleting lobby %d: %w", snapshot.ID, err)
+			}
+			continue
+		}
+		l.store = s
+		restored = append(restored, l)
+	}
+	lobbies.Lock()
+	defer lobbies.Unlock()
+	for _, l := range restored {
+		if _, ok := lobbies.ls[l.Uuid]; ok {
+			return 0, fmt.Errorf("restoring lobby %d: already exists", l.Uuid)
+		}
+	}
+	lobbies.store = s
+	for _, l := range restored {
+		lobbies.ls[l.Uuid] = l
+		go l.run()
+	}
+	return len(restored), nil
+}
+
+// takeSnapshot copies everything a Store needs to restore l.
+func (l *Lobby) takeSnapshot() Snapshot {
+	s := Snapshot{
+		ID:         l.Uuid,
+		Players:    make([]PlayerSnapshot, len(l.players)),
+		Host:       l.host,
+		Locked:     l.locked,
+		Options:    l.options,
+		Chat:       l.chatHistory(),
+		LastAction: l.lastAction,
+		FinishedAt: l.finishedAt,
+	}
+	for i, p := range l.players {
+		s.Players[i] = PlayerSnapshot{p.Name, p.token, p.muted, p.bot}
+	}
+	if l.game != nil {
+		s.Game = &GameSnapshot{
+			Ruleset:    l.game.Rules().Name,
+			LockClaims: l.game.LockClaims,
+			Events:     l.game.Events(),
+		}
+	}
+	return s
+}
+
+// restore rebuilds the Lobby saved in s, it is not registered yet.
+func restore(s Snapshot) (*Lobby, error) {
+	err := s.Options.validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Players) == 0 || int(s.Host) >= len(s.Players) {
+		return nil, fmt.Errorf("host %d of %d players", s.Host, len(s.Players))
+	}
+	l := newLobby(s.ID)
+	l.host = s.Host
+	l.locked = s.Locked
+	l.options = s.Options
+	l.chat = s.Chat
+	l.lastAction = s.LastAction
+	l.finishedAt = s.FinishedAt
+	now := time.Now()
+	for i, p := range s.Players {
+		l.players = append(l.players, Player{
+			Name:     p.Name,
+			token:    p.Token,
+			lastSeen: now,
+			presence: PresenceAway,
+			position: game.Player(i),
+			muted:    p.Muted,
+			bot:      p.Bot,
+		})
+	}
+	if s.Game != nil {
+		err = l.restoreGame(*s.Game)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.leaveBots()
+	return l, nil
+}
+
+// restoreGame replays the game saved in s.
+func (l *Lobby) restoreGame(s GameSnapshot) error {
+	rules, ok := ruleset(s.Ruleset)
+	if !ok {
+		return fmt.Errorf("unknown ruleset %s", s.Ruleset)
+	}
+	g, err := game.Replay(rules, s.Events, nil)
+	if err != nil {
+		return err
+	}
+	if len(g.Roles) != len(l.players) {
+		return fmt.Errorf("game of %d players for %d seats", len(g.Roles), len(l.players))
+	}
+	g.LockClaims = s.LockClaims
+	l.game = &g
+	return nil
+}
+
+// leaveBots gives up the seats of bots, they weren't restored.
+func (l *Lobby) leaveBots() {
+	for i := len(l.players) - 1; i >= 0; i-- {
+		if !l.players[i].bot || l.players[i].vacant() {
+			continue
+		}
+		if l.running() {
+			l.vacate(game.Player(i))
+		} else {
+			l.removePlayer(game.Player(i), ReasonLeft)
+		}
+	}
+	if len(l.players) > 0 && l.players[l.host].vacant() {
+		l.passHost()
+	}
+}
+
+// persist saves the Lobby if a player acted since the last save.
+func (l *Lobby) persist() {
+	if !l.dirty || l.store == nil {
+		return
+	}
+	err := l.store.Save(l.takeSnapshot())
+	if err != nil {
+		// kept dirty, the next command tries again
+		log.Printf("lobby %d: saving: %v", l.Uuid, err)
+		return
+	}
+	l.dirty = false
+}
+
+// FileStore saves every Lobby as json file in Dir.
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates dir if it doesn't exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir}, nil
+}
+
+func (s *FileStore) path(lobby uint) string {
+	return filepath.Join(s.Dir, strconv.FormatUint(uint64(lobby), 10)+".json")
+}
+
+// Save writes to a temporary file first, so a crash never leaves a partial Snapshot.
+func (s *FileStore) Save(snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Dir, "lobby-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(snapshot.ID))
+}
+
+func (s *FileStore) Delete(lobby uint) error {
+	err := os.Remove(s.path(lobby))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) Load() ([]Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		err = json.Unmarshal(data, &snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
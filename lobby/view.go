@@ -0,0 +1,126 @@
+package lobby
+
+import "github.com/c-goetz/traitor-card-game/game"
+
+/*
+Messages are only ever built from a game.View or game.PublicView, never from
+the game.Game itself. PlayerView embeds the game.View of its player.
+A player's View holds only their own Hand and Role, so the hidden information
+of other players can't end up in their Messages.
+*/
+
+// PlayerInfo is the public part of a Player.
+type PlayerInfo struct {
+	Seat     game.Player `json:"seat"`
+	Name     string      `json:"name"`
+	Presence Presence    `json:"presence"`
+	// Vacant seats were left during the game, the game is paused until they are taken
+	Vacant bool `json:"vacant"`
+	Muted  bool `json:"muted"`
+}
+
+// PlayerView is a snapshot of everything one player may know about a Lobby.
+// It is enough to render the Lobby from scratch, e.g. after a reconnect.
+type PlayerView struct {
+	Seat    game.Player  `json:"seat"`
+	Players []PlayerInfo `json:"players"`
+	Host    game.Player  `json:"host"`
+	Locked  bool         `json:"locked"`
+	Options Options      `json:"options"`
+	// Variant names the cards and roles
+	Variant *game.Variant `json:"variant"`
+	// Game is nil until the game was started
+	Game *game.View `json:"game"`
+	// Chat are the last messages, oldest first
+	Chat []ChatMessage `json:"chat"`
+}
+
+// Me is the PlayerInfo of the player the view belongs to.
+func (v PlayerView) Me() PlayerInfo {
+	return v.Players[v.Seat]
+}
+
+// IsHost tells if the player the view belongs to is host.
+func (v PlayerView) IsHost() bool {
+	return v.Seat == v.Host
+}
+
+func (l *Lobby) playerInfo(player game.Player) PlayerInfo {
+	p := l.players[player]
+	return PlayerInfo{p.position, p.Name, p.presence, p.vacant(), p.muted}
+}
+
+// viewOf is what seat may see of the Lobby.
+func (l *Lobby) viewOf(seat game.Player) PlayerView {
+	v := PlayerView{Seat: seat, Host: l.host, Locked: l.locked, Options: l.options, Variant: l.variant(), Chat: l.chatHistory()}
+	for _, p := range l.players {
+		v.Players = append(v.Players, l.playerInfo(p.position))
+	}
+	if l.game != nil {
+		gv := l.game.ViewOf(seat)
+		v.Game = &gv
+	}
+	return v
+}
+
+// GetView returns the PlayerView of player.
+func GetView(lobby, player uint, token string) (PlayerView, error) {
+	var v PlayerView
+	err := do(lobby, func(l *Lobby) error {
+		err := l.authorize(player, token)
+		if err != nil {
+			return err
+		}
+		v = l.viewOf(game.Player(player))
+		return nil
+	})
+	return v, err
+}
+
+// broadcastViews sends every player their PlayerView and spectators theirs.
+func (l *Lobby) broadcastViews() {
+	for _, p := range l.players {
+		l.sendTo(p.position, &ViewMessage{View: l.viewOf(p.position)})
+	}
+	l.broadcastSpectators()
+}
+
+// broadcastPublic sends every player the Message built from the public view.
+func (l *Lobby) broadcastPublic(build func(v game.PublicView) Message) {
+	l.broadcast(build(l.game.Public()))
+}
+
+// broadcastPrivate sends every player the Message built from their own view.
+func (l *Lobby) broadcastPrivate(build func(v game.View) Message) {
+	for _, p := range l.players {
+		l.sendTo(p.position, build(l.game.ViewOf(p.position)))
+	}
+}
+
+func claimMessage(player game.Player, variant *game.Variant) func(v game.PublicView) Message {
+	return func(v game.PublicView) Message {
+		return &ClaimMessage{Player: player, Cards: *v.Claims[player], Variant: variant}
+	}
+}
+
+func revealMessage(variant *game.Variant) func(v game.PublicView) Message {
+	return func(v game.PublicView) Message {
+		return &RevealCardMessage{Cards: v.Revealed, KeyHolder: v.KeyHolder, Variant: variant}
+	}
+}
+
+func stateMessage(v game.PublicView) Message {
+	return &StateMessage{State: v.State}
+}
+
+func handMessage(variant *game.Variant) func(v game.View) Message {
+	return func(v game.View) Message {
+		return &HandMessage{Cards: v.Hand, Variant: variant}
+	}
+}
+
+func roleMessage(variant *game.Variant) func(v game.View) Message {
+	return func(v game.View) Message {
+		return &RoleMessage{Role: v.Role, Variant: variant}
+	}
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 
+	"github.com/c-goetz/traitor-card-game/game"
 	"github.com/c-goetz/traitor-card-game/lobby"
 )
 
@@ -20,6 +25,14 @@ type Error int
 
 const (
 	ErrLobbyCreate Error = iota
+	ErrLobbyId
+	ErrLobbyNotFound
+	ErrLobbyFull
+	ErrNameTaken
+	ErrLobbyStarted
+	ErrLobbyJoin
+	ErrLobbyLocked
+	ErrInvalidName
 	// must be last
 	ErrLast
 )
@@ -28,6 +41,22 @@ func errorFlash(err Error) string {
 	switch err {
 	case ErrLobbyCreate:
 		return "Internal error creating lobby."
+	case ErrLobbyId:
+		return "Invalid lobby id."
+	case ErrLobbyNotFound:
+		return "Lobby does not exist."
+	case ErrLobbyFull:
+		return "Lobby is full."
+	case ErrNameTaken:
+		return "Name is already taken."
+	case ErrLobbyStarted:
+		return "Game has already started."
+	case ErrLobbyJoin:
+		return "Internal error joining lobby."
+	case ErrLobbyLocked:
+		return "Lobby is locked."
+	case ErrInvalidName:
+		return "Names must be 1 to 32 characters without control characters."
 	default:
 		return ""
 	}
@@ -38,14 +67,67 @@ type TemplateData struct {
 	Flash  string
 }
 
+type IndexTemplateData struct {
+	TemplateData
+	Variants []*game.Variant
+}
+
+type JoinTemplateData struct {
+	TemplateData
+	LobbyId string
+	Name    string
+}
+
+type WatchTemplateData struct {
+	TemplateData
+	// Event renders the view with the templates used for SpectatorViewMessage
+	Event   EventTemplateData
+	LobbyId string
+}
+
 type LobbyTemplateData struct {
 	TemplateData
-	Lobby   *lobby.Lobby
-	Player  *lobby.Player
+	View lobby.PlayerView
+	// Event renders the view with the templates used for ViewMessage
+	Event   EventTemplateData
 	Initial bool
 	LobbyId string
 }
 
+// encodeLobbyId returns the url safe representation of a lobby id
+func encodeLobbyId(id uint) string {
+	bs := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bs, uint64(id))
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+func decodeLobbyId(id string) (uint, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return 0, fmt.Errorf("decoding lobby id: %v", err)
+	}
+	if len(bs) != 8 {
+		return 0, fmt.Errorf("lobby id has invalid length: %d", len(bs))
+	}
+	return uint(binary.LittleEndian.Uint64(bs)), nil
+}
+
+// loadRulesets offers every json file in dir as game.Ruleset.
+func loadRulesets(dir string) error {
+	all, err := game.LoadRulesets(dir)
+	if err != nil {
+		return err
+	}
+	for _, rules := range all {
+		err = lobby.AddRuleset(rules)
+		if err != nil {
+			return err
+		}
+		log.Printf("loaded ruleset %s from %s", rules.Name, dir)
+	}
+	return nil
+}
+
 // TODO one dir, serve fs directly
 //go:embed static/htmx-1.7.0-min.js
 var htmx []byte
@@ -62,10 +144,56 @@ type Strings struct {
 	Join,
 	Rules,
 	Lobby,
-	PlayerName string
+	LobbyId,
+	PlayerName,
+	Players,
+	Round,
+	Player,
+	KeyHolder,
+	Claim,
+	Revealed,
+	Hand,
+	Role,
+	State,
+	Host,
+	Locked,
+	Vacant,
+	Winner,
+	Variant,
+	Watch,
+	Chat,
+	Send,
+	Muted,
+	Closed string
 }
 
 func main() {
+	janitor := lobby.DefaultJanitorConfig
+	flag.DurationVar(&janitor.IdleTTL, "lobby-idle", janitor.IdleTTL, "close lobbies without player actions for this long, 0 to disable")
+	flag.DurationVar(&janitor.FinishedGrace, "lobby-finished", janitor.FinishedGrace, "close lobbies this long after their game ended, 0 to disable")
+	flag.DurationVar(&janitor.AbandonedTTL, "lobby-abandoned", janitor.AbandonedTTL, "close lobbies this long after all players went away, 0 to disable")
+	rulesetDir := flag.String("rulesets", "", "directory of json rulesets hosts can choose besides the default")
+	storeDir := flag.String("store", "", "directory to save lobbies in, so they survive restarts, empty keeps them in memory only")
+	flag.Parse()
+	if *rulesetDir != "" {
+		err := loadRulesets(*rulesetDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *storeDir != "" {
+		store, err := lobby.NewFileStore(*storeDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// after loading rulesets, restored games may use them
+		n, err := lobby.UseStore(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("restored %d lobbies from %s", n, *storeDir)
+	}
+
 	strings := Strings{
 		// TODO i18n
 		Title:      "Traitor Card Game",
@@ -73,13 +201,34 @@ func main() {
 		Join:       "Join Existing Lobby",
 		Rules:      "View Rules",
 		Lobby:      "Lobby",
+		LobbyId:    "Lobby ID",
 		PlayerName: "Name",
+		Players:    "Players",
+		Round:      "Round",
+		Player:     "Player",
+		KeyHolder:  "Key holder",
+		Claim:      "Claim",
+		Revealed:   "Revealed",
+		Hand:       "Hand",
+		Role:       "Role",
+		State:      "State",
+		Host:       "Host",
+		Locked:     "Locked",
+		Vacant:     "Vacant",
+		Winner:     "Winner",
+		Variant:    "Game",
+		Watch:      "Watch",
+		Chat:       "Chat",
+		Send:       "Send",
+		Muted:      "Muted",
+		Closed:     "Lobby closed",
 	}
 	tsFS, err := fs.Sub(templates, "templates")
 	if err != nil {
 		log.Fatal(err)
 	}
 	ts := template.Must(template.ParseFS(tsFS, "*.html"))
+	lobby.StartJanitor(context.Background(), janitor)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/static/htmx.js", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/javascript")
@@ -91,7 +240,12 @@ func main() {
 		reader := bytes.NewReader(htmxSse)
 		io.Copy(w, reader)
 	})
-	// TODO sse
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		serveSSE(w, r, ts, strings)
+	})
+	mux.HandleFunc("/sse/watch", func(w http.ResponseWriter, r *http.Request) {
+		serveWatchSSE(w, r, ts, strings)
+	})
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/html")
 		err := r.ParseForm()
@@ -118,36 +272,150 @@ func main() {
 		}
 		switch r.URL.Path {
 		case "/":
-			data := TemplateData{strings, flash}
+			data := IndexTemplateData{TemplateData{strings, flash}, game.Variants}
 			ts.ExecuteTemplate(w, "index.html", data)
+		case "/join":
+			data := JoinTemplateData{
+				TemplateData{strings, flash},
+				r.Form.Get("id"),
+				r.Form.Get("name"),
+			}
+			ts.ExecuteTemplate(w, "join.html", data)
+		case "/watch":
+			id := r.Form.Get("id")
+			lobbyId, err := decodeLobbyId(id)
+			if err != nil {
+				log.Printf("can't watch lobby: %v", err)
+				http.Redirect(w, r, fmt.Sprintf("/?err=%d", ErrLobbyId), http.StatusSeeOther)
+				return
+			}
+			view, err := lobby.GetSpectatorView(lobbyId)
+			if err != nil {
+				log.Printf("can't watch lobby: %v", err)
+				http.Redirect(w, r, fmt.Sprintf("/?err=%d", ErrLobbyNotFound), http.StatusSeeOther)
+				return
+			}
+			data := WatchTemplateData{
+				TemplateData{strings, flash},
+				EventTemplateData{strings, &lobby.SpectatorViewMessage{View: view}},
+				id,
+			}
+			ts.ExecuteTemplate(w, "watch.html", data)
 		case "/lobby":
 			id := r.Form.Get("id")
 			if id == "" {
-				player, lobby, err := lobby.CreateLobby("Host")
+				name := r.Form.Get("name")
+				if name == "" {
+					name = "Host"
+				}
+				options := lobby.Options{Variant: r.Form.Get("variant")}
+				lobbyId, token, err := lobby.CreateLobbyWith(name, options)
 				if err != nil {
 					log.Printf("can't create lobby: %v", err)
-					http.Redirect(w, r, fmt.Sprintf("/?err=%d", ErrLobbyCreate), 301)
+					flashErr := ErrLobbyCreate
+					if errors.Is(err, lobby.ErrInvalidName) {
+						flashErr = ErrInvalidName
+					}
+					http.Redirect(w, r, fmt.Sprintf("/?err=%d", flashErr), http.StatusSeeOther)
+					return
+				}
+				view, err := lobby.GetView(lobbyId, 0, token)
+				if err != nil {
+					log.Printf("can't get host view: %v", err)
+					// nobody can reach the lobby without the token of the host
+					lobby.Close(lobbyId)
+					http.Redirect(w, r, fmt.Sprintf("/?err=%d", ErrLobbyCreate), http.StatusSeeOther)
+					return
 				}
-				bs := make([]byte, 8)
-				binary.LittleEndian.PutUint64(bs, lobby.Uuid)
-				// TODO register host channel
+				setSession(w, lobbyId, token)
 				data := LobbyTemplateData{
 					TemplateData{strings, flash},
-					lobby,
-					player,
+					view,
+					EventTemplateData{strings, &lobby.ViewMessage{View: view}},
 					true,
-					base64.RawURLEncoding.EncodeToString(bs),
+					encodeLobbyId(lobbyId),
 				}
 				ts.ExecuteTemplate(w, "lobby.html", data)
+				return
+			}
+			name := r.Form.Get("name")
+			joinError := func(err Error) {
+				query := url.Values{}
+				query.Set("id", id)
+				query.Set("name", name)
+				query.Set("err", strconv.Itoa(int(err)))
+				http.Redirect(w, r, "/join?"+query.Encode(), http.StatusSeeOther)
 			}
-			// TODO get lobby, show
+			lobbyId, err := decodeLobbyId(id)
+			if err != nil {
+				log.Printf("can't join lobby: %v", err)
+				joinError(ErrLobbyId)
+				return
+			}
+			if name == "" {
+				session, err := getSession(r)
+				if err != nil || session.Lobby != lobbyId {
+					// not seated in this lobby yet
+					http.Redirect(w, r, "/join?id="+url.QueryEscape(id), http.StatusSeeOther)
+					return
+				}
+				view, err := lobby.GetView(lobbyId, session.Seat, session.Token)
+				if err != nil {
+					log.Printf("can't get seated player view: %v", err)
+					joinError(ErrLobbyJoin)
+					return
+				}
+				data := LobbyTemplateData{
+					TemplateData{strings, flash},
+					view,
+					EventTemplateData{strings, &lobby.ViewMessage{View: view}},
+					false,
+					id,
+				}
+				ts.ExecuteTemplate(w, "lobby.html", data)
+				return
+			}
+			seat, token, err := lobby.Join(lobbyId, name)
+			if err != nil {
+				log.Printf("can't join lobby: %v", err)
+				switch {
+				case errors.Is(err, lobby.ErrLobbyNotFound):
+					joinError(ErrLobbyNotFound)
+				case errors.Is(err, lobby.ErrLobbyFull):
+					joinError(ErrLobbyFull)
+				case errors.Is(err, lobby.ErrNameTaken):
+					joinError(ErrNameTaken)
+				case errors.Is(err, lobby.ErrStarted):
+					joinError(ErrLobbyStarted)
+				case errors.Is(err, lobby.ErrLocked):
+					joinError(ErrLobbyLocked)
+				case errors.Is(err, lobby.ErrInvalidName):
+					joinError(ErrInvalidName)
+				default:
+					joinError(ErrLobbyJoin)
+				}
+				return
+			}
+			view, err := lobby.GetView(lobbyId, seat, token)
+			if err != nil {
+				log.Printf("can't get joined player view: %v", err)
+				joinError(ErrLobbyJoin)
+				return
+			}
+			setSession(w, lobbyId, token)
+			data := LobbyTemplateData{
+				TemplateData{strings, flash},
+				view,
+				EventTemplateData{strings, &lobby.ViewMessage{View: view}},
+				true,
+				id,
+			}
+			ts.ExecuteTemplate(w, "lobby.html", data)
 		default:
 			http.NotFound(w, r)
 		}
 	})
-	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("api request: %v\n", r)
-	})
+	mux.HandleFunc("/api/", serveAPI)
 	err = http.ListenAndServe(":8000", mux)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/c-goetz/traitor-card-game/lobby"
+)
+
+const sessionCookie = "session"
+
+// Session identifies the Player behind a request.
+// Browsers send it as cookie, other clients as bearer token.
+// The seat is resolved on every request.
+type Session struct {
+	Lobby uint
+	Seat  uint
+	Token string
+}
+
+var errNoSession = errors.New("no session")
+
+func setSession(w http.ResponseWriter, lobbyId uint, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sessionValue(lobbyId, token),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func sessionValue(lobbyId uint, token string) string {
+	return encodeLobbyId(lobbyId) + "." + token
+}
+
+// getSession resolves lobby and seat from the Authorization header
+// or the session cookie.
+func getSession(r *http.Request) (Session, error) {
+	var value string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		value = strings.TrimPrefix(auth, "Bearer ")
+	} else if cookie, err := r.Cookie(sessionCookie); err == nil {
+		value = cookie.Value
+	} else {
+		return Session{}, errNoSession
+	}
+	id, token, ok := strings.Cut(value, ".")
+	if !ok {
+		return Session{}, fmt.Errorf("malformed session cookie")
+	}
+	lobbyId, err := decodeLobbyId(id)
+	if err != nil {
+		return Session{}, err
+	}
+	seat, err := lobby.Authenticate(lobbyId, token)
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{lobbyId, seat, token}, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/c-goetz/traitor-card-game/lobby"
+)
+
+// EventTemplateData is passed to the templates in events.html.
+// Each Message is rendered by the template named after its kind.
+type EventTemplateData struct {
+	Static  Strings
+	Message lobby.Message
+}
+
+// heartbeatInterval must be shorter than the time after which the lobby marks players away.
+const heartbeatInterval = 10 * time.Second
+
+// serveSSE streams all Messages of a Player as Server-Sent Events.
+// The event name is the kind of the Message, the data a html fragment.
+func serveSSE(w http.ResponseWriter, r *http.Request, ts *template.Template, strings Strings) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("sse: streaming unsupported")
+		w.WriteHeader(500)
+		return
+	}
+	lobbyId, err := decodeLobbyId(r.URL.Query().Get("id"))
+	if err != nil {
+		log.Printf("sse: %v", err)
+		w.WriteHeader(400)
+		return
+	}
+	session, err := getSession(r)
+	if err != nil || session.Lobby != lobbyId {
+		log.Printf("sse: no session for lobby %d: %v", lobbyId, err)
+		w.WriteHeader(403)
+		return
+	}
+	channel := make(chan lobby.Message)
+	err = lobby.Register(lobbyId, session.Token, &channel)
+	if err != nil {
+		log.Printf("sse: register: %v", err)
+		if errors.Is(err, lobby.ErrUnauthorized) {
+			// e.g. kicked, the session is of no use anymore
+			w.WriteHeader(403)
+			return
+		}
+		w.WriteHeader(404)
+		return
+	}
+	defer lobby.UnregisterChannel(lobbyId, &channel)
+	stream(w, r, flusher, ts, strings, channel, func() error {
+		// the seat may change when others leave, the token doesn't
+		_, err := lobby.Authenticate(lobbyId, session.Token)
+		return err
+	})
+}
+
+// serveWatchSSE streams the SpectatorViews of a Lobby, no session needed.
+func serveWatchSSE(w http.ResponseWriter, r *http.Request, ts *template.Template, strings Strings) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("sse: streaming unsupported")
+		w.WriteHeader(500)
+		return
+	}
+	lobbyId, err := decodeLobbyId(r.URL.Query().Get("id"))
+	if err != nil {
+		log.Printf("sse: %v", err)
+		w.WriteHeader(400)
+		return
+	}
+	channel := make(chan lobby.Message)
+	err = lobby.Spectate(lobbyId, &channel)
+	if err != nil {
+		log.Printf("sse: spectate: %v", err)
+		w.WriteHeader(404)
+		return
+	}
+	defer lobby.StopSpectating(lobbyId, &channel)
+	stream(w, r, flusher, ts, strings, channel, nil)
+}
+
+// stream writes every Message of channel until it is closed or the client is gone.
+// alive is checked with every heartbeat if not nil, the stream ends if it fails.
+func stream(w http.ResponseWriter, r *http.Request, flusher http.Flusher, ts *template.Template, strings Strings, channel chan lobby.Message, alive func() error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if alive != nil {
+				err := alive()
+				if err != nil {
+					log.Printf("sse: authenticate: %v", err)
+					return
+				}
+			}
+			// comments keep proxies from closing idle connections
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case message, ok := <-channel:
+			if !ok {
+				// detached by the lobby, the browser will reconnect
+				return
+			}
+			err := writeMessage(w, ts, strings, message)
+			if err != nil {
+				log.Printf("sse: write %s: %v", message.GetKind(), err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeMessage(w io.Writer, ts *template.Template, strings Strings, message lobby.Message) error {
+	event := message.GetKind()
+	var buf bytes.Buffer
+	err := ts.ExecuteTemplate(&buf, event, EventTemplateData{strings, message})
+	if err != nil {
+		return err
+	}
+	return writeEvent(w, event, bytes.TrimSpace(buf.Bytes()))
+}
+
+// writeEvent writes one event, every line of data needs its own prefix.
+// Like the browser, lines end at \r\n, \r or \n, so data can't start a field of its own.
+func writeEvent(w io.Writer, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\n", event)
+	if err != nil {
+		return err
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		_, err = fmt.Fprintf(w, "data: %s\n", line)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}